@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const SessionKey = "session"
+
+// Phases of the running session.
+const (
+	PhaseIdle      = "idle"
+	PhaseFocus     = "focus"
+	PhasePaused    = "paused"
+	PhaseBreak     = "break"
+	PhaseLongBreak = "long_break"
+)
+
+// sessionGrace is how long a session outlives its planned end, after which a
+// session left behind by a killed process reads as idle.
+const sessionGrace = time.Minute
+
+// Session is the state of the running pomodoro or break, shared with other
+// processes such as `pomo status`.
+type Session struct {
+	Phase   string    `json:"phase"`
+	Task    string    `json:"task"`
+	Subtask string    `json:"subtask"`
+	Project string    `json:"project"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// Left is the time left of a paused session, which has no end.
+	Left time.Duration `json:"left,omitempty"`
+}
+
+// pausedTTL is how long a paused session is kept.
+const pausedTTL = 24 * time.Hour
+
+// Remaining returns the time left in the session at now.
+func (s Session) Remaining(now time.Time) time.Duration {
+	if s.Phase == PhasePaused {
+		return s.Left
+	}
+	if s.End.Before(now) {
+		return 0
+	}
+	return s.End.Sub(now)
+}
+
+func SetSession(session Session) error {
+	data, _ := json.Marshal(session)
+	ttl := time.Until(session.End) + sessionGrace
+	if session.Phase == PhasePaused {
+		ttl = pausedTTL
+	}
+	return redisClient.client.Set(SessionKey, data, ttl).Err()
+}
+
+// GetSession returns the running session, an idle one when none runs.
+func GetSession() (Session, error) {
+	data, err := redisClient.client.Get(SessionKey).Result()
+
+	if err == redis.Nil {
+		return Session{Phase: PhaseIdle}, nil
+	}
+
+	if err != nil {
+		return Session{}, err
+	}
+
+	var session Session
+	err = json.Unmarshal([]byte(data), &session)
+	return session, err
+}
+
+func ClearSession() error {
+	return redisClient.client.Del(SessionKey).Err()
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,7 +18,247 @@ type Configuration struct {
 	PomodoroTime int
 	StopInFirst  int
 	BreakTime    int
-	TeamID       string
+	// CycleLength is the number of pomodoros in a cycle, 4 by default.
+	CycleLength int
+	// LongBreakTime is the break in minutes after the last pomodoro of a
+	// cycle, three short breaks by default.
+	LongBreakTime int
+	Breaks        Breaks
+	TeamID        string
+	// Username is the ClickUp username used to find the tasks assigned to
+	// you.
+	Username string
+	Goals    Goals
+	// DayStartHour is the hour at which a day starts, for night owls whose
+	// sessions after midnight belong to the previous day.
+	DayStartHour int
+	// TimeZone is the IANA time zone, e.g. "Europe/Berlin", times are shown
+	// and days are counted in. The system time zone by default; timestamps
+	// are always stored in UTC.
+	TimeZone string
+	// Font is the countdown font: classic, block, shade, compact, or the
+	// path of a font file.
+	Font string
+	// Theme is the color theme: default, monochrome or high-contrast.
+	// NO_COLOR selects monochrome.
+	Theme string
+	// StatusFormat is the line printed by `pomo status`, with the
+	// placeholders {phase}, {remaining}, {elapsed}, {task}, {subtask} and
+	// {project}.
+	StatusFormat string
+	// Socket is the control socket of `pomo daemon`, pomo-<uid>.sock in the
+	// temporary directory by default.
+	Socket string
+	// ServeAddr is the address `pomo serve` listens on, localhost:8025 by
+	// default.
+	ServeAddr string
+	// Hooks are the shell commands run on the lifecycle events of a
+	// session, by event name.
+	Hooks map[string][]string
+	// Webhooks receive signed JSON payloads of saved sessions and closed
+	// days.
+	Webhooks []Webhook
+	// JournalDir is where day reviews are saved as Markdown, one <day>.md
+	// per day. Reviews are only printed when empty.
+	JournalDir string
+	// RulesFile is the reconciliation rules file, rules.yaml next to the
+	// config file by default.
+	RulesFile string
+	Rules     Rules `mapstructure:"-"`
+}
+
+// GapRule labels untracked time of a day with an activity. Every condition
+// that is set must hold for the rule to apply.
+type GapRule struct {
+	Activity string
+	// From and To restrict the rule to a window of the day as "hh:mm"; only
+	// the part of a gap inside the window is labelled. A window ending
+	// before it starts spans midnight.
+	From string
+	To   string
+	// After and Before are the activities right before and after the gap.
+	After  string
+	Before string
+	// MinGap and MaxGap bound the length of the gap, e.g. "5m".
+	MinGap time.Duration
+	MaxGap time.Duration
+}
+
+// BreakRule classifies the gap between two segments of the same activity as
+// a break or a distraction.
+type BreakRule struct {
+	Activity string
+	// MinSession is how long the segment before the gap must last for the
+	// gap to be a break.
+	MinSession time.Duration
+	// Gaps up to MaxBreak are labelled BreakLabel, longer ones below
+	// MaxDistraction are labelled DistractionLabel.
+	MaxBreak         time.Duration
+	BreakLabel       string
+	MaxDistraction   time.Duration
+	DistractionLabel string
+}
+
+// Rules are applied in order when reconciling a day: first the break rules,
+// then the gap rules.
+type Rules struct {
+	Breaks []BreakRule
+	Gaps   []GapRule
+}
+
+// defaultActivity is the activity time entries are tracked as.
+const defaultActivity = "study"
+
+// withBreakDefaults fills the unset fields of the break rules from the
+// pomodoro and break lengths in minutes, and adds a rule for the default
+// activity when none is configured.
+func withBreakDefaults(rules []BreakRule, pomodoroTime, breakTime int) []BreakRule {
+	if len(rules) == 0 {
+		rules = []BreakRule{{Activity: defaultActivity}}
+	}
+
+	pomodoro := time.Duration(pomodoroTime) * time.Minute
+	maxBreak := 2 * time.Duration(breakTime) * time.Minute
+	if maxBreak == 0 {
+		maxBreak = 10 * time.Minute
+	}
+
+	resolved := make([]BreakRule, len(rules))
+	for i, rule := range rules {
+		if rule.MinSession == 0 {
+			rule.MinSession = pomodoro * 4 / 5
+		}
+		if rule.MaxBreak == 0 {
+			rule.MaxBreak = maxBreak
+		}
+		if rule.BreakLabel == "" {
+			rule.BreakLabel = rule.Activity + "_break"
+		}
+		if rule.MaxDistraction == 0 {
+			rule.MaxDistraction = pomodoro + time.Duration(breakTime)*time.Minute
+		}
+		if rule.DistractionLabel == "" {
+			rule.DistractionLabel = rule.Activity + "_distraction"
+		}
+		resolved[i] = rule
+	}
+	return resolved
+}
+
+// RoutineStep is a step of the guided long break routine.
+type RoutineStep struct {
+	Step     string
+	Duration time.Duration
+}
+
+// Breaks holds what the break screen suggests: one of the suggestions in
+// turn during short breaks, and the routine step by step during long ones.
+type Breaks struct {
+	Suggestions []string
+	Routine     []RoutineStep
+}
+
+var defaultSuggestions = []string{
+	"Stand up and stretch",
+	"Drink a glass of water",
+	"Look at something 20 feet away for 20 seconds",
+	"Roll your shoulders and neck",
+	"Walk around for a minute",
+	"Close your eyes and take a few deep breaths",
+}
+
+var defaultRoutine = []RoutineStep{
+	{"Stand up and reach for the ceiling", time.Minute},
+	{"Roll your shoulders, then your neck", time.Minute},
+	{"Look out of a window, far away", time.Minute},
+	{"Refill your water and drink", 2 * time.Minute},
+	{"Walk around, away from the screen", 5 * time.Minute},
+}
+
+// Target is an amount of focus to reach within a period. Zero values mean
+// no target is set.
+type Target struct {
+	Pomodoros int
+	Minutes   int
+}
+
+// Goal holds the daily and weekly targets for a project.
+type Goal struct {
+	Daily  Target
+	Weekly Target
+}
+
+// Goals holds the overall targets and optional per-project targets keyed by
+// project name.
+type Goals struct {
+	Daily    Target
+	Weekly   Target
+	Projects map[string]Goal
+}
+
+// Lifecycle events of a session, the keys of Hooks.
+const (
+	HookFocusStart  = "focus_start"
+	HookFocusEnd    = "focus_end"
+	HookInterrupted = "interrupted"
+	HookBreakStart  = "break_start"
+	HookBreakEnd    = "break_end"
+)
+
+var hookEvents = map[string]bool{
+	HookFocusStart:  true,
+	HookFocusEnd:    true,
+	HookInterrupted: true,
+	HookBreakStart:  true,
+	HookBreakEnd:    true,
+}
+
+// Webhook is a URL payloads are posted to, signed with Secret. Events
+// limits the payloads to some events, all of them when empty.
+type Webhook struct {
+	URL    string
+	Secret string
+	Events []string
+}
+
+// Webhook events.
+const (
+	WebhookTimeEntry = "time_entry"
+	WebhookDayClosed = "day_closed"
+)
+
+// Wants reports whether the webhook receives event.
+func (w Webhook) Wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SocketPath returns the path of the daemon control socket.
+func (c *Configuration) SocketPath() string {
+	if c.Socket != "" {
+		return c.Socket
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("pomo-%d.sock", os.Getuid()))
+}
+
+// Location returns the configured display time zone.
+func (c *Configuration) Location() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+// IsSet reports whether the target has anything to reach.
+func (t Target) IsSet() bool {
+	return t.Pomodoros > 0 || t.Minutes > 0
 }
 
 func LoadConfig() *Configuration {
@@ -30,5 +275,80 @@ func LoadConfig() *Configuration {
 		log.Fatalf("Failed to unmarshal configuration: %s", err)
 	}
 
+	if conf.CycleLength <= 0 {
+		conf.CycleLength = 4
+	}
+	if conf.LongBreakTime <= 0 {
+		conf.LongBreakTime = 3 * conf.BreakTime
+	}
+	if conf.ServeAddr == "" {
+		conf.ServeAddr = "localhost:8025"
+	}
+	if len(conf.Breaks.Suggestions) == 0 {
+		conf.Breaks.Suggestions = defaultSuggestions
+	}
+	if len(conf.Breaks.Routine) == 0 {
+		conf.Breaks.Routine = defaultRoutine
+	}
+
+	for event := range conf.Hooks {
+		if !hookEvents[event] {
+			log.Fatalf("Unknown hook event %q", event)
+		}
+	}
+
+	// deliveries are matched to their webhook by URL
+	urls := make(map[string]bool)
+	for _, webhook := range conf.Webhooks {
+		if webhook.URL == "" || webhook.Secret == "" {
+			log.Fatalf("Webhooks need a url and a secret")
+		}
+		if urls[webhook.URL] {
+			log.Fatalf("Webhook %s is configured twice", webhook.URL)
+		}
+		urls[webhook.URL] = true
+		for _, event := range webhook.Events {
+			if event != WebhookTimeEntry && event != WebhookDayClosed {
+				log.Fatalf("Unknown webhook event %q", event)
+			}
+		}
+	}
+
+	rules, err := LoadRules(conf.RulesFile)
+	if err != nil {
+		log.Fatalf("Error reading rules file, %s", err)
+	}
+	rules.Breaks = withBreakDefaults(rules.Breaks, conf.PomodoroTime, conf.BreakTime)
+	conf.Rules = rules
+
 	return conf
 }
+
+// LoadRules reads the reconciliation rules from path, or from rules.yaml in
+// the config directories when path is empty. A missing default rules file
+// means no rules.
+func LoadRules(path string) (Rules, error) {
+	v := viper.New()
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("rules")
+		v.AddConfigPath(".")
+		v.AddConfigPath("./config")
+		v.AddConfigPath("$HOME/.config/pomo")
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path == "" && errors.As(err, &notFound) {
+			return Rules{}, nil
+		}
+		return Rules{}, err
+	}
+
+	var rules Rules
+	if err := v.Unmarshal(&rules); err != nil {
+		return Rules{}, err
+	}
+	return rules, nil
+}
@@ -3,10 +3,11 @@ package db
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/atony2099/pomo/domain"
-	_ "github.com/go-sql-driver/mysql"
+	gomysql "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -17,10 +18,30 @@ type Task struct {
 	ParentTaskID string
 	ProjectName  string
 	Duration     int64
-	TaskID       string `gorm:"primaryKey"`
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	// Estimate is the locally set number of pomodoros, TimeEstimate the
+	// ClickUp estimate in milliseconds.
+	Estimate     int   `gorm:"not null;default:0"`
+	TimeEstimate int64 `gorm:"not null;default:0"`
+	DueDate      *time.Time
+	// Priority follows ClickUp: 1 urgent, 2 high, 3 normal, 4 low, 0 none.
+	Priority int `gorm:"not null;default:0"`
+	// Tags and Assignees are comma separated names.
+	Tags      string
+	Assignees string
+	URL       string
+	TaskID    string `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// AfterFind converts the due date, stored in UTC, to the display time zone.
+func (t *Task) AfterFind(tx *gorm.DB) error {
+	if t.DueDate != nil {
+		due := t.DueDate.In(domain.Location())
+		t.DueDate = &due
+	}
+	return nil
 }
 
 type TimeEntry struct {
@@ -46,8 +67,19 @@ type DB struct {
 
 var dbs *DB
 
+// Connect opens the database and migrates it. Times are stored in UTC
+// whatever loc the DSN sets; the DSN loc is only used to convert the rows
+// stored before.
 func Connect(dbDSN string) error {
-	db, err := gorm.Open(mysql.Open(dbDSN), &gorm.Config{
+	cfg, err := gomysql.ParseDSN(dbDSN)
+	if err != nil {
+		return fmt.Errorf("failed to parse DSN: %v", err)
+	}
+	storedIn := cfg.Loc
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+
+	db, err := gorm.Open(mysql.Open(cfg.FormatDSN()), &gorm.Config{
 		// Logger: logger.Default.LogMode(logger.Info),
 	})
 	if err != nil {
@@ -55,7 +87,10 @@ func Connect(dbDSN string) error {
 	}
 	dbs = &DB{db}
 
-	return nil
+	if err := Migrate(); err != nil {
+		return err
+	}
+	return migrateToUTC(storedIn)
 }
 
 func GetTasks() ([]Task, error) {
@@ -86,6 +121,8 @@ func InsertOrUpdateTasks(tasks []domain.TaskInfo, spaces []domain.Space) error {
 			task.Status = taskInfo.Status.Status
 			task.ParentTaskID = taskInfo.Parent
 			task.ProjectName = spaceName
+			task.TimeEstimate = timeEstimate(taskInfo)
+			setMetadata(&task, taskInfo)
 			err := dbs.db.Save(&task)
 			if err.Error != nil {
 				return fmt.Errorf("failed to update task %s: %v", task.TaskID, err.Error)
@@ -97,7 +134,9 @@ func InsertOrUpdateTasks(tasks []domain.TaskInfo, spaces []domain.Space) error {
 				Status:       taskInfo.Status.Status,
 				ParentTaskID: taskInfo.Parent,
 				ProjectName:  spaceName,
+				TimeEstimate: timeEstimate(taskInfo),
 			}
+			setMetadata(&task, taskInfo)
 			err := dbs.db.Create(&task)
 			if err.Error != nil {
 				return fmt.Errorf("failed to insert task %s: %v", task.TaskID, err.Error)
@@ -108,6 +147,58 @@ func InsertOrUpdateTasks(tasks []domain.TaskInfo, spaces []domain.Space) error {
 	return nil
 }
 
+func timeEstimate(taskInfo domain.TaskInfo) int64 {
+	if taskInfo.TimeEstimate == nil {
+		return 0
+	}
+	return *taskInfo.TimeEstimate
+}
+
+// setMetadata copies the due date, priority, tags, assignees and url of a
+// ClickUp task.
+func setMetadata(task *Task, taskInfo domain.TaskInfo) {
+	task.DueDate = nil
+	if due, err := strconv.ParseInt(taskInfo.DueDate, 10, 64); err == nil {
+		dueDate := time.UnixMilli(due)
+		task.DueDate = &dueDate
+	}
+
+	task.Priority = 0
+	if taskInfo.Priority != nil {
+		task.Priority, _ = strconv.Atoi(taskInfo.Priority.ID)
+	}
+
+	var tags, assignees []string
+	for _, tag := range taskInfo.Tags {
+		tags = append(tags, tag.Name)
+	}
+	for _, assignee := range taskInfo.Assignees {
+		assignees = append(assignees, assignee.Username)
+	}
+	task.Tags = strings.Join(tags, ",")
+	task.Assignees = strings.Join(assignees, ",")
+	task.URL = taskInfo.URL
+}
+
+// GetTask returns the task with the given id.
+func GetTask(taskID string) (Task, error) {
+	var task Task
+	err := dbs.db.Where("task_id = ?", taskID).First(&task).Error
+	return task, err
+}
+
+// SetTaskEstimate sets the local estimate in pomodoros of a task.
+func SetTaskEstimate(taskID string, pomodoros int) error {
+	result := dbs.db.Model(&Task{}).Where("task_id = ?", taskID).Update("estimate", pomodoros)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("task %s not found", taskID)
+	}
+	return nil
+}
+
 func InsertOrUpdateEntries(entry []domain.TimeEntryInfo) error {
 
 	for _, entry := range entry {
@@ -189,13 +280,66 @@ func InsertOrUpdateEntries(entry []domain.TimeEntryInfo) error {
 // 	return nil
 // }
 
-func SelectTimeEntry(day string) ([]domain.TimeEntry, error) {
+// SelectTimeEntry returns the time entries overlapping [start, end).
+func SelectTimeEntry(start, end time.Time) ([]domain.TimeEntry, error) {
 	var tasks []domain.TimeEntry
 
-	err := dbs.db.Where("date(start_time) = ? or date(end_time) = ?", day, day).Find(&tasks).Error
+	err := dbs.db.Where("start_time < ? and end_time > ?", end, start).Order("start_time").Find(&tasks).Error
 	return tasks, err
 }
 
+// SelectEntriesWithProject returns the time entries overlapping [start, end)
+// joined with the project name of their task.
+func SelectEntriesWithProject(start, end time.Time) ([]domain.ProjectEntry, error) {
+	var entries []domain.ProjectEntry
+
+	err := dbs.db.Table("time_entries").
+		Select("time_entries.*, tasks.project_name, tasks.name as task_title").
+		Joins("left join tasks on tasks.task_id = time_entries.task_id").
+		Where("time_entries.deleted_at is null and time_entries.start_time < ? and time_entries.end_time > ?", end, start).
+		Order("time_entries.start_time").
+		Scan(&entries).Error
+	// Scan skips the AfterFind hook
+	for i := range entries {
+		entries[i].StartTime = entries[i].StartTime.In(domain.Location())
+		entries[i].EndTime = entries[i].EndTime.In(domain.Location())
+	}
+	return entries, err
+}
+
+// SelectTaskEntries returns every time entry recorded for a task.
+func SelectTaskEntries(taskID string) ([]domain.TimeEntry, error) {
+	var entries []domain.TimeEntry
+
+	err := dbs.db.Where("task_id = ?", taskID).Order("start_time").Find(&entries).Error
+	return entries, err
+}
+
+// SelectLatestTimeEntry returns the time entry that ended last.
+func SelectLatestTimeEntry() (domain.TimeEntry, error) {
+	var entry domain.TimeEntry
+
+	err := dbs.db.Order("end_time desc").First(&entry).Error
+	return entry, err
+}
+
+// GetTimeEntry returns the time entry with the given id.
+func GetTimeEntry(id string) (domain.TimeEntry, error) {
+	var entry domain.TimeEntry
+
+	err := dbs.db.Where("id = ?", id).First(&entry).Error
+	return entry, err
+}
+
+// SetTimeEntryNote replaces the note of a time entry.
+func SetTimeEntryNote(id, note string) error {
+	entry, err := GetTimeEntry(id)
+	if err != nil {
+		return err
+	}
+	return dbs.db.Model(&entry).Update("note", note).Error
+}
+
 func SaveTimeEntry(entry domain.TimeEntry) error {
 	err := dbs.db.Create(&entry).Error
 	return err
@@ -225,11 +369,14 @@ func SelectTotalDurationGroupByTask() ([]domain.TaskSummary, error) {
 
 }
 
+// CreateDailyTracker creates the tracker unless the same segment, with the
+// same start, activity and time entry, already exists.
 func CreateDailyTracker(tracker domain.DailyTracker) error {
 
-	// check if the tracker.start_time already exists
 	var count int64
-	dbs.db.Model(&domain.DailyTracker{}).Where("start_time = ?", tracker.StartTime).Count(&count)
+	dbs.db.Model(&domain.DailyTracker{}).
+		Where("start_time = ? and activity = ? and time_entry_id = ?", tracker.StartTime, tracker.Activity, tracker.TimeEntryID).
+		Count(&count)
 	if count > 0 {
 		return nil
 	}
@@ -238,13 +385,200 @@ func CreateDailyTracker(tracker domain.DailyTracker) error {
 	return err
 }
 
-func SelectDailyTracker(date string) ([]domain.DailyTracker, error) {
-	var trackers []domain.DailyTracker
+// SaveDailyTracker creates the tracker, or updates every field of it when it
+// already has an id.
+func SaveDailyTracker(tracker *domain.DailyTracker) error {
+	return dbs.db.Save(tracker).Error
+}
+
+// DeleteDailyTracker deletes the tracker with the given id.
+func DeleteDailyTracker(id uint) error {
+	return dbs.db.Delete(&domain.DailyTracker{}, id).Error
+}
+
+// GetDailyTracker returns the tracker with the given id.
+func GetDailyTracker(id uint) (domain.DailyTracker, error) {
+	var tracker domain.DailyTracker
+	err := dbs.db.First(&tracker, id).Error
+	return tracker, err
+}
 
-	// selct end_time  is date ,
-	// if end_time is null, select start_time is date
+// ValidateDailyTracker checks that the tracker does not end before it starts
+// and does not overlap another tracker than the excluded ones.
+func ValidateDailyTracker(tracker domain.DailyTracker, exclude ...uint) error {
+	return validateDailyTracker(dbs.db, tracker, exclude...)
+}
+
+func validateDailyTracker(tx *gorm.DB, tracker domain.DailyTracker, exclude ...uint) error {
+	if tracker.EndTime != nil && !tracker.EndTime.After(tracker.StartTime) {
+		return fmt.Errorf("end time %s is not after start time %s", tracker.EndTime.Format("15:04"), tracker.StartTime.Format("15:04"))
+	}
+
+	query := tx.Where("end_time is null or end_time > ?", tracker.StartTime)
+	if tracker.EndTime != nil {
+		query = query.Where("start_time < ?", *tracker.EndTime)
+	}
+	if tracker.ID != 0 {
+		exclude = append(exclude, tracker.ID)
+	}
+	if len(exclude) > 0 {
+		query = query.Where("id not in ?", exclude)
+	}
+
+	var overlapping []domain.DailyTracker
+	if err := query.Find(&overlapping).Error; err != nil {
+		return err
+	}
+	if len(overlapping) > 0 {
+		other := overlapping[0]
+		return fmt.Errorf("overlaps segment %d %s starting %s", other.ID, other.Activity, other.StartTime.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// EditDailyTracker saves the changed tracker after validating it.
+func EditDailyTracker(tracker domain.DailyTracker) error {
+	return dbs.db.Transaction(func(tx *gorm.DB) error {
+		if err := validateDailyTracker(tx, tracker); err != nil {
+			return err
+		}
+		return tx.Save(&tracker).Error
+	})
+}
 
-	err := dbs.db.Where("date(start_time) = ? or date(end_time) = ?", date, date).Order("start_time").Find(&trackers).Error
+// SplitDailyTracker ends the tracker at `at` and creates a second tracker of
+// the given activity from `at` to the original end.
+func SplitDailyTracker(id uint, at time.Time, activity string) (domain.DailyTracker, error) {
+	var second domain.DailyTracker
+	err := dbs.db.Transaction(func(tx *gorm.DB) error {
+		var first domain.DailyTracker
+		if err := tx.First(&first, id).Error; err != nil {
+			return err
+		}
+		if !at.After(first.StartTime) || (first.EndTime != nil && !at.Before(*first.EndTime)) {
+			return fmt.Errorf("split time %s is outside segment %d", at.Format("15:04"), id)
+		}
+
+		if activity == "" {
+			activity = first.Activity
+		}
+		second = domain.DailyTracker{Activity: activity, StartTime: at, EndTime: first.EndTime}
+		end := at
+		first.EndTime = &end
+
+		if err := tx.Save(&first).Error; err != nil {
+			return err
+		}
+		return tx.Create(&second).Error
+	})
+	return second, err
+}
+
+// MergeDailyTrackers extends the first tracker over the second one, which is
+// deleted. Both must be entered by hand and adjacent or overlapping, and the
+// merged span must not overlap any other tracker.
+func MergeDailyTrackers(id, otherID uint) (domain.DailyTracker, error) {
+	var merged domain.DailyTracker
+	err := dbs.db.Transaction(func(tx *gorm.DB) error {
+		var other domain.DailyTracker
+		if err := tx.First(&merged, id).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&other, otherID).Error; err != nil {
+			return err
+		}
+
+		// a segment linked to a time entry is reset to the bounds of its
+		// entry on the next reconciliation, which would undo the merge
+		if merged.TimeEntryID != "" || other.TimeEntryID != "" {
+			return fmt.Errorf("cannot merge segments linked to time entries")
+		}
+		first, second := merged, other
+		if second.StartTime.Before(first.StartTime) {
+			first, second = second, first
+		}
+		if first.EndTime != nil && second.StartTime.After(*first.EndTime) {
+			return fmt.Errorf("segments %d and %d are neither adjacent nor overlapping", first.ID, second.ID)
+		}
+
+		merged.StartTime = first.StartTime
+		if merged.EndTime == nil || other.EndTime == nil {
+			merged.EndTime = nil
+		} else if other.EndTime.After(*merged.EndTime) {
+			merged.EndTime = other.EndTime
+		}
+
+		if err := validateDailyTracker(tx, merged, other.ID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&other).Error; err != nil {
+			return err
+		}
+		return tx.Save(&merged).Error
+	})
+	return merged, err
+}
+
+// SelectOpenDailyTracker returns the latest tracker without an end time, or
+// gorm.ErrRecordNotFound when none is running.
+func SelectOpenDailyTracker() (domain.DailyTracker, error) {
+	return selectOpenDailyTracker(dbs.db)
+}
+
+func selectOpenDailyTracker(tx *gorm.DB) (domain.DailyTracker, error) {
+	var tracker domain.DailyTracker
+	err := tx.Where("end_time is null").Order("start_time desc").First(&tracker).Error
+	return tracker, err
+}
+
+// StopDailyTracker ends the running tracker at the given time.
+func StopDailyTracker(at time.Time) (domain.DailyTracker, error) {
+	var tracker domain.DailyTracker
+	err := dbs.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		tracker, err = stopDailyTracker(tx, at)
+		return err
+	})
+	return tracker, err
+}
+
+func stopDailyTracker(tx *gorm.DB, at time.Time) (domain.DailyTracker, error) {
+	tracker, err := selectOpenDailyTracker(tx)
+	if err != nil {
+		return tracker, err
+	}
+	tracker.EndTime = &at
+	if err := validateDailyTracker(tx, tracker); err != nil {
+		return tracker, err
+	}
+	return tracker, tx.Save(&tracker).Error
+}
+
+// SwitchDailyTracker ends the running tracker, if any, and starts a tracker
+// of the given activity at the same time. The stopped tracker has a zero id
+// when none was running.
+func SwitchDailyTracker(at time.Time, activity string) (stopped, started domain.DailyTracker, err error) {
+	err = dbs.db.Transaction(func(tx *gorm.DB) error {
+		stopped, err = stopDailyTracker(tx, at)
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		started = domain.DailyTracker{Activity: activity, StartTime: at}
+		if err := validateDailyTracker(tx, started); err != nil {
+			return err
+		}
+		return tx.Create(&started).Error
+	})
+	return stopped, started, err
+}
+
+// SelectDailyTracker returns the trackers overlapping [start, end), including
+// the ones still running.
+func SelectDailyTracker(start, end time.Time) ([]domain.DailyTracker, error) {
+	var trackers []domain.DailyTracker
+
+	err := dbs.db.Where("start_time < ? and (end_time > ? or end_time is null)", end, start).Order("start_time").Find(&trackers).Error
 	return trackers, err
 }
 
@@ -267,9 +601,86 @@ func GetAllActivityName() ([]string, error) {
 
 }
 
+// GetActivityTypes returns the activity catalogue sorted by name.
+func GetActivityTypes() ([]domain.ActivityType, error) {
+	var types []domain.ActivityType
+	err := dbs.db.Order("name").Find(&types).Error
+	return types, err
+}
+
+// SaveActivityType creates or updates an activity of the catalogue.
+func SaveActivityType(activityType domain.ActivityType) error {
+	return dbs.db.Save(&activityType).Error
+}
+
+// RenameActivity renames every tracker of the activity from to the activity
+// to, and drops from from the catalogue. It returns the number of renamed
+// trackers.
+func RenameActivity(from, to string) (int64, error) {
+	if from == to {
+		return 0, fmt.Errorf("cannot rename %s to itself", from)
+	}
+	var renamed int64
+	err := dbs.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.DailyTracker{}).Where("activity = ?", from).Update("activity", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		renamed = result.RowsAffected
+		return tx.Delete(&domain.ActivityType{}, "name = ?", from).Error
+	})
+	return renamed, err
+}
+
 func UpdateDailyTracker(tracker domain.DailyTracker) error {
 
 	// where start_time = tracker.start_time and activity = tracker.activity
 	err := dbs.db.Model(&domain.DailyTracker{}).Where("start_time = ? and activity = ?", tracker.StartTime, tracker.Activity).Updates(&tracker).Error
 	return err
 }
+
+// QueueWebhookDeliveries adds deliveries to the webhook queue.
+func QueueWebhookDeliveries(deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return dbs.db.Create(&deliveries).Error
+}
+
+// SelectWebhookDeliveries returns the queued deliveries in the order they
+// were queued, only those due at due unless it is zero.
+func SelectWebhookDeliveries(due time.Time) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+
+	query := dbs.db.Order("id")
+	if !due.IsZero() {
+		query = query.Where("next_attempt <= ?", due)
+	}
+	err := query.Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimWebhookDelivery postpones a delivery due at due to until, so that no
+// other process posts it meanwhile. It reports whether the delivery was still
+// due, false when another process claimed or delivered it first.
+func ClaimWebhookDelivery(id uint, due, until time.Time) (bool, error) {
+	result := dbs.db.Model(&domain.WebhookDelivery{}).
+		Where("id = ? and next_attempt <= ?", id, due).
+		Update("next_attempt", until)
+	return result.RowsAffected == 1, result.Error
+}
+
+// SaveWebhookDelivery records a failed attempt of a delivery. A delivery
+// deleted meanwhile is left deleted.
+func SaveWebhookDelivery(delivery domain.WebhookDelivery) error {
+	return dbs.db.Model(&domain.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"attempts":     delivery.Attempts,
+		"last_error":   delivery.LastError,
+		"next_attempt": delivery.NextAttempt,
+	}).Error
+}
+
+// DeleteWebhookDelivery removes a delivered delivery from the queue.
+func DeleteWebhookDelivery(id uint) error {
+	return dbs.db.Delete(&domain.WebhookDelivery{}, id).Error
+}
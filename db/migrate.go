@@ -0,0 +1,143 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atony2099/pomo/domain"
+	"gorm.io/gorm"
+)
+
+// setting is a value pomo keeps about the database itself.
+type setting struct {
+	Name  string `gorm:"primaryKey;size:191"`
+	Value string
+}
+
+// tables lists the tables created by pomo itself.
+var tables = []interface{}{
+	&domain.ActivityType{},
+	&domain.WebhookDelivery{},
+	&setting{},
+}
+
+// columns lists the columns added to existing tables after they were
+// created by hand, keyed by the Go field name.
+var columns = []struct {
+	model  interface{}
+	fields []string
+}{
+	{&domain.TimeEntry{}, []string{"Outcome", "PlannedDuration", "ActualDuration", "Note"}},
+	{&Task{}, []string{"Estimate", "TimeEstimate", "DueDate", "Priority", "Tags", "Assignees", "URL"}},
+	{&domain.DailyTracker{}, []string{"TimeEntryID"}},
+}
+
+// indexes lists the indexes declared on fields added by columns.
+var indexes = []struct {
+	model interface{}
+	field string
+}{
+	{&domain.DailyTracker{}, "TimeEntryID"},
+}
+
+// Migrate creates the missing tables and adds the columns missing from the
+// existing ones.
+func Migrate() error {
+	migrator := dbs.db.Migrator()
+	for _, table := range tables {
+		if migrator.HasTable(table) {
+			continue
+		}
+		if err := migrator.CreateTable(table); err != nil {
+			return fmt.Errorf("failed to create table: %v", err)
+		}
+	}
+	for _, c := range columns {
+		for _, field := range c.fields {
+			if migrator.HasColumn(c.model, field) {
+				continue
+			}
+			if err := migrator.AddColumn(c.model, field); err != nil {
+				return fmt.Errorf("failed to add column %s: %v", field, err)
+			}
+		}
+	}
+	for _, index := range indexes {
+		if migrator.HasIndex(index.model, index.field) {
+			continue
+		}
+		if err := migrator.CreateIndex(index.model, index.field); err != nil {
+			return fmt.Errorf("failed to create index on %s: %v", index.field, err)
+		}
+	}
+	return nil
+}
+
+// utcSetting records that the stored times were converted to UTC.
+const utcSetting = "times_in_utc"
+
+// timeColumns lists the time columns of every table, with its primary key.
+var timeColumns = []struct {
+	table   string
+	key     string
+	columns []string
+}{
+	{"time_entries", "id", []string{"start_time", "end_time", "created_at", "updated_at", "deleted_at"}},
+	{"daily_trackers", "id", []string{"start_time", "end_time", "created_at", "updated_at", "deleted_at"}},
+	{"tasks", "task_id", []string{"due_date", "created_at", "updated_at", "deleted_at"}},
+	{"activity_types", "name", []string{"created_at", "updated_at"}},
+}
+
+// migrateToUTC converts, once, the times stored as wall clock times of the
+// location from to UTC.
+func migrateToUTC(from *time.Location) error {
+	var count int64
+	if err := dbs.db.Model(&setting{}).Where("name = ?", utcSetting).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to read settings: %v", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	err := dbs.db.Transaction(func(tx *gorm.DB) error {
+		if from != time.UTC {
+			for _, t := range timeColumns {
+				if err := convertToUTC(tx, t.table, t.key, t.columns, from); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Create(&setting{Name: utcSetting, Value: from.String()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to convert times to UTC: %v", err)
+	}
+	return nil
+}
+
+func convertToUTC(tx *gorm.DB, table, key string, columns []string, from *time.Location) error {
+	if !tx.Migrator().HasTable(table) {
+		return nil
+	}
+
+	var rows []map[string]interface{}
+	if err := tx.Table(table).Select(append([]string{key}, columns...)).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		changes := make(map[string]interface{})
+		for _, column := range columns {
+			// read back as UTC, the stored wall clock is that of from
+			if t, ok := row[column].(time.Time); ok {
+				changes[column] = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), from).UTC()
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if err := tx.Table(table).Where(key+" = ?", row[key]).UpdateColumns(changes).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,6 +6,24 @@ import (
 	"gorm.io/gorm"
 )
 
+// location is the display time zone, the system one unless configured.
+var location = time.Local
+
+// SetLocation sets the time zone times are shown and days are counted in.
+func SetLocation(loc *time.Location) {
+	location = loc
+}
+
+// Location returns the display time zone.
+func Location() *time.Location {
+	return location
+}
+
+// Now returns the current time in the display time zone.
+func Now() time.Time {
+	return time.Now().In(location)
+}
+
 // APIError represents a ClickUp API error response
 type APIError struct {
 	Error string `json:"err"`
@@ -43,6 +61,23 @@ type TaskInfo struct {
 	Space  struct {
 		ID string `json:"id"`
 	} `json:"space"`
+	// TimeEstimate is in milliseconds, nil when no estimate is set
+	TimeEstimate *int64 `json:"time_estimate"`
+	// DueDate is in milliseconds since the epoch, empty when not set
+	DueDate  string `json:"due_date"`
+	Priority *struct {
+		ID       string `json:"id"`
+		Priority string `json:"priority"`
+	} `json:"priority"`
+	Tags []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+	Assignees []struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	} `json:"assignees"`
+	URL string `json:"url"`
 }
 
 type TaskResponse struct {
@@ -76,6 +111,9 @@ type TaskSummary struct {
 //   `task_name` varchar(255) NOT NULL,
 //   `start_time` datetime NOT NULL,
 //   `end_time` datetime NOT NULL,
+//   `outcome` varchar(32) NOT NULL DEFAULT '',
+//   `planned_duration` bigint NOT NULL DEFAULT 0,
+//   `actual_duration` bigint NOT NULL DEFAULT 0,
 //   `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
 //   `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 //   `deleted_at` datetime DEFAULT NULL,
@@ -92,6 +130,55 @@ type TimeEntry struct {
 	TaskName  string
 	StartTime time.Time
 	EndTime   time.Time
+
+	// Outcome is how the session ended, empty for entries recorded before
+	// outcomes were tracked.
+	Outcome string `gorm:"size:32;not null;default:''"`
+	// PlannedDuration and ActualDuration are in seconds.
+	PlannedDuration int64 `gorm:"not null;default:0"`
+	ActualDuration  int64 `gorm:"not null;default:0"`
+	// Note is free text attached to the session with `pomo note`.
+	Note string `gorm:"type:text"`
+}
+
+// Completed reports whether the session ran its full length. Entries recorded
+// before outcomes were tracked are judged by their length.
+func (e TimeEntry) Completed(pomodoroDuration time.Duration) bool {
+	if e.Outcome != "" {
+		return e.Outcome == OutcomeCompleted
+	}
+	return e.EndTime.Sub(e.StartTime) >= pomodoroDuration
+}
+
+// AfterFind converts the times, stored in UTC, to the display time zone.
+func (e *TimeEntry) AfterFind(tx *gorm.DB) error {
+	e.StartTime = e.StartTime.In(location)
+	e.EndTime = e.EndTime.In(location)
+	return nil
+}
+
+// Outcomes of a pomodoro session.
+const (
+	OutcomeCompleted   = "completed"
+	OutcomeInterrupted = "interrupted"
+)
+
+// ProjectEntry is a time entry together with the project of its task.
+type ProjectEntry struct {
+	TimeEntry
+	ProjectName string
+	TaskTitle   string
+}
+
+// Title returns the best known name of the entry's task.
+func (e ProjectEntry) Title() string {
+	if e.TaskName != "" {
+		return e.TaskName
+	}
+	if e.TaskTitle != "" {
+		return e.TaskTitle
+	}
+	return e.TaskID
 }
 
 type DailyTracker struct {
@@ -100,8 +187,56 @@ type DailyTracker struct {
 	StartTime time.Time
 	// EndTime   time.Time set end_time to nullable
 
-	EndTime   *time.Time
+	EndTime *time.Time
+	// TimeEntryID links a segment to the time entry it was created from,
+	// empty for segments entered by hand or derived from gaps.
+	TimeEntryID string `gorm:"size:255;index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+// AfterFind converts the times, stored in UTC, to the display time zone.
+func (t *DailyTracker) AfterFind(tx *gorm.DB) error {
+	t.StartTime = t.StartTime.In(location)
+	if t.EndTime != nil {
+		end := t.EndTime.In(location)
+		t.EndTime = &end
+	}
+	return nil
+}
+
+// ActivityType is an entry of the activity catalogue. Category groups
+// activities in reports, e.g. work, rest, sleep or leisure.
+type ActivityType struct {
+	Name     string `gorm:"primaryKey;size:191"`
+	Category string
+	Color    string
+	// Aliases are comma separated alternative names.
+	Aliases   string
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// WebhookDelivery is a payload waiting to be posted to a webhook. It is kept
+// until the webhook accepts it, so deliveries survive restarts and outages.
+type WebhookDelivery struct {
+	ID      uint   `gorm:"primaryKey"`
+	URL     string `gorm:"size:2048;not null"`
+	Event   string `gorm:"size:64;not null"`
+	Payload string `gorm:"type:text;not null"`
+	// Attempts counts the failed deliveries, the last one failing with
+	// LastError. The next one is due at NextAttempt.
+	Attempts    int `gorm:"not null;default:0"`
+	LastError   string
+	NextAttempt time.Time `gorm:"index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// AfterFind converts the times, stored in UTC, to the display time zone.
+func (d *WebhookDelivery) AfterFind(tx *gorm.DB) error {
+	d.NextAttempt = d.NextAttempt.In(location)
+	d.CreatedAt = d.CreatedAt.In(location)
+	return nil
 }
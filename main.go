@@ -3,11 +3,14 @@ package main
 import (
 	"flag"
 	"log"
+	"strconv"
 
 	"github.com/atony2099/pomo/cache"
 	"github.com/atony2099/pomo/config"
 	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
 	"github.com/atony2099/pomo/task"
+	"github.com/atony2099/pomo/ui"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/nsf/termbox-go"
 )
@@ -16,12 +19,20 @@ import (
 func main() {
 
 	var setFlag = flag.Bool("set", false, "set pomodoro config")
+	// task picker filters
+	var mineFlag = flag.Bool("mine", false, "only tasks assigned to me")
+	var dueFlag = flag.String("due", "", "only tasks due today, week or overdue")
+	var priorityFlag = flag.String("priority", "", "only tasks at this priority or more urgent")
+	var tagFlag = flag.String("tag", "", "only tasks with this tag")
+	var sortFlag = flag.String("sort", "", "sort tasks by due or priority")
 	var taskFlag = flag.Bool("sync", false, "get task list")
 	// select specify day
 
 	var total = flag.Bool("total", false, "total duration")
 
 	var completeFlag = flag.Int("complete", -1, "complete task")
+	var batchFlag = flag.Bool("batch", false, "complete without prompting, for cron")
+	var dryRunFlag = flag.Bool("dry-run", false, "show what complete would insert without saving")
 
 	var logFlg = flag.Int("log", -1, "select the activity log")
 
@@ -31,20 +42,83 @@ func main() {
 	flag.Parse()
 
 	config := config.LoadConfig()
+	location, err := config.Location()
+	if err != nil {
+		log.Fatalf("Invalid time zone %q: %v", config.TimeZone, err)
+	}
+	domain.SetLocation(location)
+	task.SetDayStartHour(config.DayStartHour)
+	task.SetWebhooks(config.Webhooks)
 	//
-	err := cache.NewClient(config.RedisURL)
+	err = cache.NewClient(config.RedisURL)
 	if err != nil {
 		log.Fatalf("Error initializing cache: %v", err)
 	}
 
+	// the status line is polled by status bars and ctl talks to the daemon,
+	// they only need the cache
+	switch flag.Arg(0) {
+	case "status":
+		task.ShowStatus(config.StatusFormat, config.SocketPath())
+		return
+	case "ctl":
+		task.CtlCommand(flag.Args()[1:], config.SocketPath())
+		return
+	}
+
 	//
 	err = db.Connect(config.DBDSN)
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
 
+	switch flag.Arg(0) {
+	case "goals":
+		task.ShowGoals(config.Goals)
+		return
+	case "stats":
+		task.ShowStats(config.PomodoroTime)
+		return
+	case "activity":
+		task.ActivityCommand(flag.Args()[1:])
+		return
+	case "review":
+		task.ReviewCommand(flag.Args()[1:], config)
+		return
+	case "note":
+		task.NoteCommand(flag.Args()[1:])
+		return
+	case "daemon":
+		task.RunDaemon(config)
+		return
+	case "serve":
+		task.ServeCommand(flag.Args()[1:], config)
+		return
+	case "webhooks":
+		task.WebhooksCommand(flag.Args()[1:])
+		return
+	case "estimate":
+		if flag.NArg() < 2 {
+			task.ShowEstimates(config.PomodoroTime)
+			return
+		}
+		pomodoros, err := strconv.Atoi(flag.Arg(1))
+		if err != nil || pomodoros < 0 {
+			log.Fatalf("Invalid estimate %q", flag.Arg(1))
+		}
+		task.SetEstimate(pomodoros)
+		return
+	}
+
 	if *setFlag {
-		task.SetPomodoroConfig()
+		filter := task.TaskFilter{Due: *dueFlag, Priority: *priorityFlag, Tag: *tagFlag, Sort: *sortFlag}
+		if *mineFlag {
+			if config.Username == "" {
+				log.Fatalf("Set username in the config to filter your tasks")
+			}
+			filter.Assignee = config.Username
+		}
+		task.SetPomodoroConfig(filter)
 		return
 	}
 	if *taskFlag {
@@ -63,7 +137,7 @@ func main() {
 	}
 
 	if *completeFlag >= 0 {
-		task.Complete(*completeFlag)
+		task.Complete(*completeFlag, task.CompleteOptions{Interactive: !*batchFlag, Rules: config.Rules, DryRun: *dryRunFlag, JournalDir: config.JournalDir, CloseDay: true})
 		return
 	}
 
@@ -72,12 +146,19 @@ func main() {
 		return
 	}
 
+	if err := ui.SetFont(config.Font); err != nil {
+		log.Fatalf("Error loading font %q: %v", config.Font, err)
+	}
+	if err := ui.SetTheme(config.Theme); err != nil {
+		log.Fatalf("Error setting theme: %v", err)
+	}
+
 	err = termbox.Init()
 	if err != nil {
 		log.Fatalf("Error initializing termbox: %v", err)
 	}
 	defer termbox.Close()
-	task := task.NewTaskHandler(config.AuthKey, config.TeamID, config.PomodoroTime, config.StopInFirst, config.BreakTime)
+	task := task.NewTaskHandler(config)
 
 	task.RunPomodoro()
 
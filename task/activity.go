@@ -6,28 +6,32 @@ import (
 	"log"
 	"os"
 
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/atony2099/pomo/config"
 	"github.com/atony2099/pomo/db"
 	"github.com/atony2099/pomo/domain"
 )
 
-const defaultActivity = "study"
-const pomodoroDuration = 20 * time.Minute
-
 // Define the struct to model the daily_trackers table
 
 func GetActivities(offset int) {
-	date := time.Now().AddDate(0, 0, -offset)
-	day := date.Format("2006-01-02")
+	date := domain.Now().AddDate(0, 0, -offset)
+	day := dayKey(date)
+	dayStart := startOfDay(date)
+	dayEnd := dayStart.AddDate(0, 0, 1)
 
 	// Fetch activities from daily_trackers for the given date
-	var activities, err = db.SelectDailyTracker(day)
+	var activities, err = selectDayTrackers(day)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	c, err := loadCatalogue()
 	if err != nil {
-		fmt.Printf("Error selecting daily tracker from day: %v\n", err)
+		fmt.Printf("%v\n", err)
 		return
 	}
 
@@ -36,169 +40,300 @@ func GetActivities(offset int) {
 		// fmt.Printf("%s: %s - %s\n", activity.Activity, activity.StartTime.Format("15:04:05"), activity.EndTime.Format("15:04:05"))
 
 		// align the output
-		fmt.Printf("%-20s: %s - %s\n", activity.Activity, activity.StartTime.Format("15:04:05"), activity.EndTime.Format("15:04:05"))
+		name := c.colorize(activity.Activity, fmt.Sprintf("%-20s", activity.Activity))
+		fmt.Printf("%5d %s: %s - %s\n", activity.ID, name, activity.StartTime.Format("15:04:05"), formatEnd(activity.EndTime))
 
 	}
-	// group by activity name
+	// group by activity name and by category
 
 	var maps = make(map[string]time.Duration)
+	var categories = make(map[string]time.Duration)
 	for _, activity := range activities {
-		if _, ok := maps[activity.Activity]; !ok {
-			maps[activity.Activity] = activity.EndTime.Sub(activity.StartTime)
-		} else {
-			maps[activity.Activity] += activity.EndTime.Sub(activity.StartTime)
+		if activity.EndTime == nil {
+			continue
 		}
+		// only the part of the segment on this day counts
+		duration := clip(activity.StartTime, *activity.EndTime, dayStart, dayEnd)
+		maps[activity.Activity] += duration
+		categories[c.category(activity.Activity)] += duration
 	}
 	fmt.Println("\nTotal duration for each activity:")
 	for k, v := range maps {
 		// align the output
+		fmt.Printf("%s: %v\n", c.colorize(k, fmt.Sprintf("%-20s", k)), v)
+	}
+	fmt.Println("\nTotal duration for each category:")
+	for k, v := range categories {
 		fmt.Printf("%-20s: %v\n", k, v)
 	}
 
+	fmt.Printf("\n%s\n", c.timeline(activities, dayStart, 30*time.Minute))
+	fmt.Println(timelineAxis(dayStart))
+
 	fmt.Println()
 }
 
-func completeNullEndTime(day string) {
+// formatEnd formats the end of a segment, which is unset while it runs.
+func formatEnd(end *time.Time) string {
+	if end == nil {
+		return "open"
+	}
+	return end.Format("15:04:05")
+}
+
+// readClock prompts until a valid "hhmm" time is entered and returns its
+// first occurrence from base on. An empty answer returns ok false.
+func readClock(reader *bufio.Reader, prompt string, base time.Time) (t time.Time, ok bool) {
+	for {
+		fmt.Print(prompt)
+		input, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(input)
+		if input == "" {
+			return time.Time{}, false
+		}
+		t, err := parseClockFrom(base, input)
+		if err == nil {
+			return t, true
+		}
+		fmt.Printf("%v\n", err)
+	}
+}
+
+func completeNullEndTime(day string) error {
 	// Fetch activities from daily_trackers for the given date
-	var activities, err = db.SelectDailyTracker(day)
+	var activities, err = selectDayTrackers(day)
 	if err != nil {
-		fmt.Printf("Error selecting daily tracker from day: %v\n", err)
-		return
+		return err
 	}
 
+	reader := bufio.NewReader(os.Stdin)
 	for _, activity := range activities {
 		if activity.EndTime == nil {
 			// prompt for end time
-
-			reader := bufio.NewReader(os.Stdin)
-			fmt.Printf("Enter end time for %s,which start time is %s: ", activity.Activity, activity.StartTime.Format("15:04:05"))
-			endTime, _ := reader.ReadString('\n')
-			endTime = strings.TrimSpace(endTime)
-
-			if endTime == "" {
-				now := time.Now()
-				activity.EndTime = &now
-			} else {
-				hourInt, err := strconv.Atoi(endTime[:2])
-				if err != nil {
-					log.Fatalf("error converting to int: %v", err)
-				}
-				minuteInt, err := strconv.Atoi(endTime[2:])
-				if err != nil {
-					log.Fatalf("error converting to int: %v", err)
-				}
-
-				endTimes := time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), hourInt, minuteInt, 0, 0, time.Local)
-				activity.EndTime = &endTimes
-
+			prompt := fmt.Sprintf("Enter end time for %s,which start time is %s: ", activity.Activity, activity.StartTime.Format("15:04:05"))
+			endTime, ok := readClock(reader, prompt, activity.StartTime)
+			if !ok {
+				endTime = domain.Now()
 			}
+			activity.EndTime = &endTime
+
 			err = db.UpdateDailyTracker(activity)
 			if err != nil {
-				log.Fatalf("error updating daily tracker: %v", err)
+				return fmt.Errorf("error updating daily tracker: %v", err)
 			}
 
 		}
 	}
+	return nil
+}
+
+// CompleteOptions controls how a day is reconciled.
+type CompleteOptions struct {
+	// Interactive prompts for open segments and for the gaps no rule
+	// resolves. Without it Complete never reads stdin.
+	Interactive bool
+	Rules       config.Rules
+	// DryRun prints the segments that would be inserted without saving
+	// anything.
+	DryRun bool
+	// JournalDir is where the review of the day is saved, if set.
+	JournalDir string
+	// CloseDay tells the webhooks that the day is closed. Only an explicit
+	// `pomo -complete` does, the runs after each pomodoro reconcile a day that
+	// is not over.
+	CloseDay bool
 }
-func Complete(offset int) {
+
+// Complete reconciles a day: time entries become "study" segments, breaks
+// between them and the gaps matched by the rules are labelled, and in
+// interactive mode the rest is prompted for. The review of the day is shown
+// at the end.
+func Complete(offset int, opts CompleteOptions) {
 	// fmt.Println("Complete task")
 
-	date := time.Now().AddDate(0, 0, -offset)
+	date := domain.Now().AddDate(0, 0, -offset)
 
 	// format to 2006-01-02
-	day := date.Format("2006-01-02")
+	day := dayKey(date)
+	dayStart := startOfDay(date)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	if opts.DryRun {
+		opts.Interactive = false
+	}
 
 	// first complet the activity which end time is null
-	completeNullEndTime(day)
+	if opts.Interactive {
+		if err := completeNullEndTime(day); err != nil {
+			fmt.Printf("Error completing open activities: %v\n", err)
+			return
+		}
+	}
 
 	// Fetch activities from time_entries for the given date
-	var entries, err = db.SelectTimeEntry(day)
+	var entries, err = db.SelectTimeEntry(dayStart, dayEnd)
 	if err != nil {
 		fmt.Printf("Error selecting task from day: %v\n", err)
 		return
 	}
 
-	// Insert fetched activities into daily_trackers as "study"
+	r, err := newReconciliation(day, opts.DryRun)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	// Insert fetched activities into daily_trackers as "study", updating
+	// the segments already linked to them
+	entryIDs := make(map[string]bool)
 	for _, entry := range entries {
 
-		// if entry.start time is privous day, set it to 00:00:00
-		if entry.StartTime.Format("2006-01-02") != day {
-			entry.StartTime = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+		// an entry crossing the start or the end of the day only gets its
+		// portion on this day, the other day gets the rest
+		if entry.StartTime.Before(dayStart) {
+			entry.StartTime = dayStart
 		}
-		if entry.EndTime.Format("2006-01-02") != day {
-			// to last minute of the day
-			entry.EndTime = time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 0, 0, date.Location())
+		if entry.EndTime.After(dayEnd) {
+			entry.EndTime = dayEnd
 		}
 		// fmt.Printf("Inserted 'study' activity from %s to %s\n", entry.StartTime.Format("15:04:05"), entry.EndTime.Format("15:04:05"))
 
 		// remove the seconds part for start and end time
 		entry.StartTime = entry.StartTime.Truncate(time.Minute)
 		entry.EndTime = entry.EndTime.Truncate(time.Minute)
+		if !entry.EndTime.After(entry.StartTime) {
+			continue
+		}
 
+		endTime := entry.EndTime
 		dailyTracker := domain.DailyTracker{
-			Activity:  "study", // Setting activity name to "study"
-			StartTime: entry.StartTime,
-			EndTime:   &entry.EndTime,
+			Activity:    "study", // Setting activity name to "study"
+			StartTime:   entry.StartTime,
+			EndTime:     &endTime,
+			TimeEntryID: entry.ID,
+		}
+		if err := r.upsertEntry(dailyTracker); err != nil {
+			fmt.Printf("%v\n", err)
+			return
 		}
-		db.CreateDailyTracker(dailyTracker)
+		entryIDs[entry.ID] = true
 		// fmt.Printf("Inserted 'study' activity from %s to %s\n", entry.StartTime.Format("15:04:05"), entry.EndTime.Format("15:04:05"))
 	}
 
+	if err := r.removeStale(entryIDs); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	overlaps, err := r.resolveOverlaps()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	for _, pair := range overlaps {
+		fmt.Printf("Overlapping segments: %d %s %s - %s and %d %s %s - %s\n",
+			pair[0].ID, pair[0].Activity, pair[0].StartTime.Format("15:04:05"), formatEnd(pair[0].EndTime),
+			pair[1].ID, pair[1].Activity, pair[1].StartTime.Format("15:04:05"), formatEnd(pair[1].EndTime))
+	}
+
 	// if two entry gap is less than 10 minutes, and the activity all is "study", create a new entry activity name "study_break" with the gap time
 
 	// Assuming 'entries' contains the sorted study activities for the date
 
-	err = insertStudyBreak(day)
+	err = insertStudyBreak(r, opts.Rules.Breaks)
 	if err != nil {
 		fmt.Printf("Error inserting study break: %v\n", err)
 		return
 	}
 
-	GetActivities(offset)
+	gaps, err := applyGapRules(r, opts.Rules.Gaps)
+	if err != nil {
+		fmt.Printf("Error applying rules: %v\n", err)
+		return
+	}
 
-	fillMissingActivities(day)
+	if !opts.Interactive {
+		if !opts.DryRun {
+			ShowDayReview(offset, opts.Rules.Breaks, opts.JournalDir)
+			if opts.CloseDay {
+				closeDay(day, opts.Rules.Breaks)
+			}
+		}
+		if len(gaps) > 0 {
+			fmt.Println("Unresolved gaps:")
+			for _, g := range gaps {
+				fmt.Println(g)
+			}
+		}
+		return
+	}
 
 	GetActivities(offset)
 
-}
+	if err := fillMissingActivities(day); err != nil {
+		fmt.Printf("Error filling missing activities: %v\n", err)
+		return
+	}
 
-func insertStudyBreak(date string) error {
+	fmt.Println()
+	ShowDayReview(offset, opts.Rules.Breaks, opts.JournalDir)
+	if opts.CloseDay {
+		closeDay(day, opts.Rules.Breaks)
+	}
 
-	// Fetch activities from daily_trackers for the given date
-	var activities, err = db.SelectDailyTracker(date)
-	if err != nil {
-		return fmt.Errorf("error selecting daily tracker from day: %v", err)
+}
+
+// closeDay tells the webhooks that the day was reconciled.
+func closeDay(day string, rules []config.BreakRule) {
+	if err := queueDayClosed(day, rules); err != nil {
+		fmt.Printf("Error queueing webhook: %v\n", err)
 	}
+}
 
-	// if two activity gap is
-	//less than 10 minutes, and the two activity all is "study",
-	// and  two activity is durartion all greater than 25 minutes
-	//create a new entry activity name "study_break" with the gap time
+// insertStudyBreak labels the gaps between two segments of the same activity
+// as breaks or distractions according to the break rules.
+func insertStudyBreak(r *reconciliation, rules []config.BreakRule) error {
+
+	// the segments are iterated over a copy as add inserts into r.trackers
+	activities := append([]domain.DailyTracker(nil), r.trackers...)
 
 	var lastEndTime time.Time
 	var lastActivity string
 	var lastDuration time.Duration
 	for _, activity := range activities {
-		if activity.Activity == defaultActivity {
-			if activity.StartTime.Sub(lastEndTime) <= 10*time.Minute && lastActivity == defaultActivity && lastDuration >= pomodoroDuration {
-				// create a new entry activity name "study_break" with the gap time
-				dailyTracker := domain.DailyTracker{
-					Activity:  "study_break", // Setting activity name to "study"
-					StartTime: lastEndTime,
-					EndTime:   &activity.StartTime,
-				}
-				db.CreateDailyTracker(dailyTracker)
-			} else if activity.StartTime.Sub(lastEndTime) < 30*time.Minute && lastActivity == defaultActivity {
-				// create a new entry activity name "study_break" with the gap time
-				dailyTracker := domain.DailyTracker{
-					Activity:  "study_distraction", // Setting activity name to "study"
-					StartTime: lastEndTime,
-					EndTime:   &activity.StartTime,
-				}
-				db.CreateDailyTracker(dailyTracker)
+		if activity.EndTime == nil {
+			// the open segment is still running
+			break
+		}
+
+		gap := activity.StartTime.Sub(lastEndTime)
+		for _, rule := range rules {
+			if activity.Activity != rule.Activity || lastActivity != rule.Activity || gap < time.Minute {
+				continue
 			}
 
+			label := ""
+			if gap <= rule.MaxBreak && lastDuration >= rule.MinSession {
+				label = rule.BreakLabel
+			} else if gap < rule.MaxDistraction {
+				label = rule.DistractionLabel
+			}
+			if label == "" {
+				break
+			}
+
+			startTime, endTime := lastEndTime, activity.StartTime
+			err := r.add(domain.DailyTracker{
+				Activity:  label,
+				StartTime: startTime,
+				EndTime:   &endTime,
+			})
+			if err != nil {
+				return err
+			}
+			break
 		}
+
 		lastEndTime = *activity.EndTime
 		lastActivity = activity.Activity
 		lastDuration = activity.EndTime.Sub(activity.StartTime)
@@ -208,145 +343,99 @@ func insertStudyBreak(date string) error {
 
 }
 
-func fillMissingActivities(date string) {
+func fillMissingActivities(date string) error {
 	fmt.Printf("\n%s  ", date)
 
-fill:
-
-	startTime, _ := time.ParseInLocation("2006-01-02", date, time.Local)
-	// trunacate to minute
-	startTime = startTime.Truncate(time.Minute)
-
-	lastEndTime := startTime
-	activities, _ := db.SelectDailyTracker(date)
-	for _, activity := range activities {
-		if activity.StartTime.After(lastEndTime) && activity.StartTime.Sub(lastEndTime) > time.Minute {
-			// fmt.Printf("lastEndTime222: %s\n", lastEndTime.Format("15:04:05"))
-			change := promptForActivity(lastEndTime, activity.StartTime)
-			if change {
-				goto fill
-			}
-		}
-		lastEndTime = *activity.EndTime
+	dayStart, dayEnd, err := dayBounds(date)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("lastEndTime: %s\n", lastEndTime.Format("15:04:05"))
+	reader := bufio.NewReader(os.Stdin)
 
-	var endTime time.Time
-	if date == time.Now().Format("2006-01-02") {
-		endTime = time.Now()
-	} else {
-		endTime = startTime.Add(24*time.Hour - time.Minute)
-	}
-	endTime = endTime.Truncate(time.Minute)
-	if endTime.After(lastEndTime) {
-		change := promptForActivity(lastEndTime, endTime)
-		if change {
-			goto fill
+	// every answer may change the following gaps, so look them up again
+	for {
+		activities, err := selectDayTrackers(date)
+		if err != nil {
+			return err
+		}
+		gaps := findGaps(activities, dayStart, dayEnd)
+		if len(gaps) == 0 {
+			return nil
+		}
+		if err := promptForActivity(reader, gaps[0]); err != nil {
+			return err
 		}
-
 	}
-
 }
 
-func promptForActivity(startTime, endTime time.Time) bool {
+// promptForActivity asks which activity filled the gap, and optionally the
+// part of the gap it covered.
+func promptForActivity(reader *bufio.Reader, g gap) error {
 
-	fmt.Printf("[%s - %s], total %v \n", startTime.Format("15:04:05"), endTime.Format("15:04:05"), endTime.Sub(startTime))
+	fmt.Println(g)
 
 	var activity domain.DailyTracker
-	reader := bufio.NewReader(os.Stdin)
 	activity.Activity = getActivities(reader)
-	var changeEndTime bool
-	var start, end string
-
-	fmt.Print("enter [hhmm-hhmm]  or use leave blank to use default: ")
-	startEnd, _ := reader.ReadString('\n')
-	startEnd = strings.TrimSpace(startEnd)
-	startEnds := strings.Split(startEnd, "-")
-
-	if len(startEnds) == 2 {
-		start = startEnds[0]
-		end = startEnds[1]
-	} else {
-		start = startEnds[0]
-	}
 
-	if start == "" {
-		activity.StartTime = startTime
-	} else {
-
-		hourInt, err := strconv.Atoi(start[:2])
-		if err != nil {
-			log.Fatalf("error converting to int: %v", err)
-		}
-		minuteInt, err := strconv.Atoi(start[2:])
+	for {
+		fmt.Print("enter [hhmm-hhmm]  or use leave blank to use default: ")
+		startEnd, _ := reader.ReadString('\n')
+		start, end, err := parseSpan(strings.TrimSpace(startEnd), g)
 		if err != nil {
-			log.Fatalf("error converting to int: %v", err)
+			fmt.Printf("%v\n", err)
+			continue
 		}
-
-		activity.StartTime = time.Date(startTime.Year(), startTime.Month(), startTime.Day(), hourInt, minuteInt, 0, 0, time.Local)
-		changeEndTime = true
-	}
-
-	if end == "" {
-		activity.EndTime = &endTime
-	} else {
-		hourInt, err := strconv.Atoi(end[:2])
-		if err != nil {
-			log.Fatalf("error converting to int: %v", err)
-		}
-		minuteInt, err := strconv.Atoi(end[2:])
-		if err != nil {
-			log.Fatalf("error converting to int: %v", err)
-		}
-		// activity.EndTime = &(time.Date(endTime.Year(), endTime.Month(), endTime.Day(), hourInt, minuteInt, 0, 0, time.Local))
-
-		endTime := time.Date(startTime.Year(), startTime.Month(), startTime.Day(), hourInt, minuteInt, 0, 0, time.Local)
-		activity.EndTime = &endTime
-
-		changeEndTime = true
-	}
-
-	if activity.EndTime.Before(activity.StartTime) {
-		log.Fatalf("end time is before start time")
+		activity.StartTime = start
+		activity.EndTime = &end
+		break
 	}
 
 	err := db.CreateDailyTracker(activity)
 	if err != nil {
-		log.Fatalf("error creating daily tracker: %v", err)
+		return fmt.Errorf("error creating daily tracker: %v", err)
 	}
 
-	return changeEndTime
+	return nil
 }
 
-func getActivities(reader *bufio.Reader) string {
-
-	choices, err := db.GetAllActivityName()
-	if err != nil {
-		log.Fatalf("error fetching distinct values %v", err)
+// parseSpan parses "hhmm-hhmm" or a single start "hhmm" within the gap; the
+// missing parts default to the gap's own bounds.
+func parseSpan(input string, g gap) (time.Time, time.Time, error) {
+	start, end := g.Start, g.End
+	if input == "" {
+		return start, end, nil
 	}
-	sort.Strings(choices)
 
-	for index, v := range choices {
-		fmt.Printf("%d:%s, ", index+1, v)
+	startEnds := strings.Split(input, "-")
+	if len(startEnds) > 2 {
+		return start, end, fmt.Errorf("invalid span %q", input)
 	}
 
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
-
-	if input == "" {
-		return "play"
+	var err error
+	if startEnds[0] != "" {
+		if start, err = parseClockFrom(startOfDay(g.Start), startEnds[0]); err != nil {
+			return start, end, err
+		}
+	}
+	if len(startEnds) == 2 && startEnds[1] != "" {
+		if end, err = parseClockFrom(startOfDay(g.Start), startEnds[1]); err != nil {
+			return start, end, err
+		}
 	}
 
-	if num, err := strconv.Atoi(input); err == nil && num > 0 && num <= len(choices) {
-		return choices[num-1]
+	if !end.After(start) {
+		return start, end, fmt.Errorf("end time is before start time")
 	}
+	return start, end, nil
+}
 
-	for _, v := range choices {
-		if strings.HasPrefix(v, input) {
-			return v
-		}
+func getActivities(reader *bufio.Reader) string {
+
+	activity, err := promptActivity(reader)
+	if err != nil {
+		log.Fatalf("error reading activity: %v", err)
 	}
-	return input
+	return activity
 }
 
 func CrateActiveStart() {
@@ -354,7 +443,7 @@ func CrateActiveStart() {
 	// crate a new activity which start time is current time, and end time is null, activity name is user input
 
 	var activity domain.DailyTracker
-	activity.StartTime = time.Now()
+	activity.StartTime = domain.Now()
 
 	// get activity name
 	reader := bufio.NewReader(os.Stdin)
@@ -0,0 +1,273 @@
+package task
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+	"gorm.io/gorm"
+)
+
+const activityUsage = `usage: pomo activity <command> [arguments]
+
+  status
+  types
+  define [-category c] [-color c] [-alias a,b] <name>
+  rename <old> <new>
+  stop [hhmm]
+  switch <name>
+  edit [-activity name] [-start hhmm] [-end hhmm] <id>
+  split [-activity name] <id> <hhmm>
+  merge <id> <other-id>
+  delete <id>`
+
+// ActivityCommand runs a `pomo activity` subcommand on daily tracker
+// segments, as listed with -log.
+func ActivityCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println(activityUsage)
+		return
+	}
+
+	var err error
+	switch args[0] {
+	case "status":
+		err = activityStatus()
+	case "stop":
+		err = stopActivity(args[1:])
+	case "switch":
+		err = switchActivity(args[1:])
+	case "types":
+		err = listActivityTypes()
+	case "define":
+		err = defineActivityType(args[1:])
+	case "rename":
+		err = renameActivity(args[1:])
+	case "edit":
+		err = editActivity(args[1:])
+	case "split":
+		err = splitActivity(args[1:])
+	case "merge":
+		err = mergeActivities(args[1:])
+	case "delete":
+		err = deleteActivity(args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", args[0], activityUsage)
+	}
+
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+}
+
+func parseTrackerID(arg string) (uint, error) {
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid segment id %q", arg)
+	}
+	return uint(id), nil
+}
+
+// getTracker returns the segment whose id is given as argument.
+func getTracker(arg string) (domain.DailyTracker, error) {
+	id, err := parseTrackerID(arg)
+	if err != nil {
+		return domain.DailyTracker{}, err
+	}
+	tracker, err := db.GetDailyTracker(id)
+	if err != nil {
+		return domain.DailyTracker{}, fmt.Errorf("error getting segment %d: %v", id, err)
+	}
+	return tracker, nil
+}
+
+func printTracker(action string, tracker domain.DailyTracker) {
+	fmt.Printf("%s %d %-20s: %s - %s\n", action, tracker.ID, tracker.Activity, tracker.StartTime.Format("2006-01-02 15:04:05"), formatEnd(tracker.EndTime))
+	if tracker.TimeEntryID != "" {
+		fmt.Printf("segment is linked to time entry %s, reconciling the day restores its times\n", tracker.TimeEntryID)
+	}
+}
+
+func editActivity(args []string) error {
+	flags := flag.NewFlagSet("edit", flag.ContinueOnError)
+	activity := flags.String("activity", "", "new activity name")
+	start := flags.String("start", "", "new start time, hhmm")
+	end := flags.String("end", "", "new end time, hhmm")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: pomo activity edit [-activity name] [-start hhmm] [-end hhmm] <id>")
+	}
+
+	tracker, err := getTracker(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *activity != "" {
+		tracker.Activity = *activity
+	}
+	day := startOfDay(tracker.StartTime)
+	if *start != "" {
+		if tracker.StartTime, err = parseClockFrom(day, *start); err != nil {
+			return err
+		}
+	}
+	if *end != "" {
+		endTime, err := parseClockFrom(day, *end)
+		if err != nil {
+			return err
+		}
+		tracker.EndTime = &endTime
+	}
+
+	if err := db.EditDailyTracker(tracker); err != nil {
+		return fmt.Errorf("error editing segment %d: %v", tracker.ID, err)
+	}
+	printTracker("edited", tracker)
+	return nil
+}
+
+func splitActivity(args []string) error {
+	flags := flag.NewFlagSet("split", flag.ContinueOnError)
+	activity := flags.String("activity", "", "activity of the second part, the same by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: pomo activity split [-activity name] <id> <hhmm>")
+	}
+
+	tracker, err := getTracker(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	at, err := parseClockFrom(tracker.StartTime, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	second, err := db.SplitDailyTracker(tracker.ID, at, *activity)
+	if err != nil {
+		return fmt.Errorf("error splitting segment %d: %v", tracker.ID, err)
+	}
+	tracker.EndTime = &at
+	printTracker("split", tracker)
+	printTracker("created", second)
+	return nil
+}
+
+func mergeActivities(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pomo activity merge <id> <other-id>")
+	}
+	id, err := parseTrackerID(args[0])
+	if err != nil {
+		return err
+	}
+	otherID, err := parseTrackerID(args[1])
+	if err != nil {
+		return err
+	}
+	if id == otherID {
+		return fmt.Errorf("cannot merge segment %d with itself", id)
+	}
+
+	merged, err := db.MergeDailyTrackers(id, otherID)
+	if err != nil {
+		return fmt.Errorf("error merging segments %d and %d: %v", id, otherID, err)
+	}
+	printTracker("merged", merged)
+	return nil
+}
+
+func deleteActivity(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: pomo activity delete <id>")
+	}
+	tracker, err := getTracker(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := db.DeleteDailyTracker(tracker.ID); err != nil {
+		return fmt.Errorf("error deleting segment %d: %v", tracker.ID, err)
+	}
+	fmt.Printf("deleted %d %-20s: %s - %s\n", tracker.ID, tracker.Activity, tracker.StartTime.Format("2006-01-02 15:04:05"), formatEnd(tracker.EndTime))
+	if tracker.TimeEntryID != "" {
+		fmt.Printf("warning: the segment comes from time entry %s, the next reconciliation recreates it\n", tracker.TimeEntryID)
+	}
+	return nil
+}
+
+func activityStatus() error {
+	tracker, err := db.SelectOpenDailyTracker()
+	if err == gorm.ErrRecordNotFound {
+		fmt.Println("no activity running")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting running activity: %v", err)
+	}
+
+	fmt.Printf("%d %s since %s (%v)\n", tracker.ID, tracker.Activity, tracker.StartTime.Format("2006-01-02 15:04"), time.Since(tracker.StartTime).Round(time.Second))
+	return nil
+}
+
+func stopActivity(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: pomo activity stop [hhmm]")
+	}
+
+	at := domain.Now()
+	if len(args) == 1 {
+		var err error
+		if at, err = parseClock(at, args[0]); err != nil {
+			return err
+		}
+	}
+
+	tracker, err := db.StopDailyTracker(at)
+	if err == gorm.ErrRecordNotFound {
+		return fmt.Errorf("no activity running")
+	}
+	if err != nil {
+		return fmt.Errorf("error stopping activity: %v", err)
+	}
+	fmt.Printf("stopped %s after %v\n", tracker.Activity, tracker.EndTime.Sub(tracker.StartTime).Round(time.Second))
+	return nil
+}
+
+func switchActivity(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: pomo activity switch <name>")
+	}
+
+	c, err := loadCatalogue()
+	if err != nil {
+		return err
+	}
+	input := strings.Join(args, " ")
+	activity, err := c.resolve(input)
+	if err == errUnknownActivity {
+		return fmt.Errorf("unknown activity %q, add it with pomo activity define", input)
+	}
+	if err != nil {
+		return err
+	}
+
+	stopped, started, err := db.SwitchDailyTracker(domain.Now(), activity)
+	if err != nil {
+		return fmt.Errorf("error switching activity: %v", err)
+	}
+	if stopped.ID != 0 {
+		fmt.Printf("stopped %s after %v\n", stopped.Activity, stopped.EndTime.Sub(stopped.StartTime).Round(time.Second))
+	}
+	fmt.Printf("started %s at %s\n", started.Activity, started.StartTime.Format("15:04"))
+	return nil
+}
@@ -0,0 +1,152 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/atony2099/pomo/cache"
+	"github.com/atony2099/pomo/domain"
+	"github.com/atony2099/pomo/ui"
+	"github.com/nsf/termbox-go"
+)
+
+// daemonCommand sends command to the daemon and returns the session after it.
+func (h *TaskHandler) daemonCommand(command string) (cache.Session, error) {
+	// an interrupted pomodoro is reconciled here, interactively
+	resp, err := daemonCall(h.socket, daemonRequest{Command: command, Reconciles: true})
+	if err != nil {
+		return cache.Session{}, err
+	}
+	if !resp.OK {
+		return resp.Session, errors.New(resp.Error)
+	}
+	return resp.Session, nil
+}
+
+// runAttached shows the session of the daemon, starting a pomodoro when it is
+// idle, until the session is stopped, its break is over or q detaches from
+// it. The daemon keeps the time, the keys are sent to it as commands.
+func (h *TaskHandler) runAttached(events <-chan termbox.Event) {
+	session, err := h.daemonCommand(CommandStatus)
+	if err == nil && session.Phase == cache.PhaseIdle {
+		session, err = h.daemonCommand(CommandStart)
+	}
+	if err != nil {
+		termbox.Close()
+		fmt.Printf("Error starting pomodoro: %v\n", err)
+		return
+	}
+
+	countdown := h.countdown()
+	countdown.Attached = true
+	first := 0
+	if len(h.breaks.Suggestions) > 0 {
+		first = rand.Intn(len(h.breaks.Suggestions))
+	}
+
+	draw := func() {
+		now := domain.Now()
+		switch session.Phase {
+		case cache.PhaseFocus, cache.PhasePaused:
+			countdown.Paused = session.Phase == cache.PhasePaused
+			countdown.Elapsed = h.pomodoroDuration - session.Remaining(now)
+			ui.DrawCountdown(countdown)
+		case cache.PhaseBreak, cache.PhaseLongBreak:
+			long := session.Phase == cache.PhaseLongBreak
+			elapsed := now.Sub(session.Start)
+			breakScreen := ui.Break{
+				Total:    session.End.Sub(session.Start),
+				Elapsed:  elapsed,
+				Long:     long,
+				Guide:    suggestion(h.breaks.Suggestions, first, elapsed),
+				Task:     session.Task,
+				Subtask:  session.Subtask,
+				Attached: true,
+			}
+			if long {
+				if step, ok := routineStep(h.breaks.Routine, elapsed); ok {
+					breakScreen.Guide = step
+				}
+			}
+			ui.DrawBreak(breakScreen)
+		}
+	}
+	draw()
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		command := ""
+		select {
+		case ev := <-events:
+			if ev.Type == termbox.EventResize {
+				draw()
+				continue
+			}
+			if ev.Type != termbox.EventKey {
+				continue
+			}
+			if ev.Ch == 'q' {
+				return
+			}
+			command = attachedCommand(session.Phase, ev)
+			if command == "" {
+				continue
+			}
+		case <-ticker.C:
+			command = CommandStatus
+		}
+
+		previous := session.Phase
+		session, err = h.daemonCommand(command)
+		if err != nil {
+			termbox.Close()
+			fmt.Printf("Error calling daemon: %v\n", err)
+			return
+		}
+
+		switch {
+		case session.Phase == cache.PhaseIdle && command == CommandStop && previous != cache.PhaseBreak && previous != cache.PhaseLongBreak:
+			// as in the foreground, an interrupted pomodoro is reviewed
+			termbox.Close()
+			ui.ClearScreen()
+			Complete(0, CompleteOptions{Interactive: true, Rules: h.rules, JournalDir: h.journalDir})
+			return
+		case session.Phase == cache.PhaseIdle:
+			return
+		case session.Phase == cache.PhaseFocus && previous != cache.PhaseFocus && previous != cache.PhasePaused:
+			// the last pomodoro was saved, count it
+			countdown = h.countdown()
+			countdown.Attached = true
+		}
+		draw()
+	}
+}
+
+// attachedCommand returns the daemon command a key stands for in phase.
+func attachedCommand(phase string, ev termbox.Event) string {
+	switch phase {
+	case cache.PhaseFocus, cache.PhasePaused:
+		switch {
+		case ev.Key == termbox.KeyEsc || ev.Key == termbox.KeySpace:
+			return CommandStop
+		case ev.Ch == 'p' && phase == cache.PhaseFocus:
+			return CommandPause
+		case ev.Ch == 'p':
+			return CommandResume
+		}
+	case cache.PhaseBreak, cache.PhaseLongBreak:
+		switch {
+		case ev.Ch == 's' || ev.Key == termbox.KeyEsc || ev.Key == termbox.KeySpace:
+			return CommandStop
+		case ev.Ch == 'e':
+			return CommandExtend
+		case ev.Ch == 'n' || ev.Key == termbox.KeyEnter:
+			return CommandStart
+		}
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package task
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// suggestionInterval is how long each break suggestion is shown.
+const suggestionInterval = 30 * time.Second
+
+// suggestion returns the suggestion shown after elapsed, rotating through
+// the list from first on.
+func suggestion(suggestions []string, first int, elapsed time.Duration) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return suggestions[(first+int(elapsed/suggestionInterval))%len(suggestions)]
+}
+
+// routineStep describes the step of the routine due after elapsed, and
+// reports false once the routine is over.
+func routineStep(routine []config.RoutineStep, elapsed time.Duration) (string, bool) {
+	var end time.Duration
+	for i, step := range routine {
+		end += step.Duration
+		if elapsed < end {
+			left := end - elapsed
+			return fmt.Sprintf("%d/%d %s (%d:%02d)", i+1, len(routine), step.Step, int(left.Minutes()), int(left.Seconds())%60), true
+		}
+	}
+	return "", false
+}
+
+// completedToday returns the number of pomodoros completed since the start of
+// the day.
+func (h *TaskHandler) completedToday() (int, error) {
+	now := domain.Now()
+	dayStart := startOfDay(now)
+	entries, err := db.SelectTimeEntry(dayStart, now)
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for _, entry := range entries {
+		if !entry.StartTime.Before(dayStart) && entry.Completed(h.pomodoroDuration) {
+			completed++
+		}
+	}
+	return completed, nil
+}
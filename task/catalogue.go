@@ -0,0 +1,324 @@
+package task
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// ansiColors maps the catalogue color names to ANSI escape codes.
+var ansiColors = map[string]string{
+	"black":   "\033[30m",
+	"red":     "\033[31m",
+	"green":   "\033[32m",
+	"yellow":  "\033[33m",
+	"blue":    "\033[34m",
+	"magenta": "\033[35m",
+	"cyan":    "\033[36m",
+	"white":   "\033[37m",
+}
+
+const ansiReset = "\033[0m"
+
+// catalogue is the activity catalogue indexed by name and alias.
+type catalogue struct {
+	types   []domain.ActivityType
+	byName  map[string]domain.ActivityType
+	aliases map[string]string
+}
+
+// loadCatalogue returns the defined activities followed by the names found
+// in the tracker history that are not defined yet.
+func loadCatalogue() (*catalogue, error) {
+	types, err := db.GetActivityTypes()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching activity types: %v", err)
+	}
+	names, err := db.GetAllActivityName()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching activity names: %v", err)
+	}
+
+	c := &catalogue{
+		byName:  make(map[string]domain.ActivityType),
+		aliases: make(map[string]string),
+	}
+	for _, t := range types {
+		c.add(t)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := c.byName[name]; !ok {
+			c.add(domain.ActivityType{Name: name})
+		}
+	}
+	return c, nil
+}
+
+func (c *catalogue) add(t domain.ActivityType) {
+	c.types = append(c.types, t)
+	c.byName[t.Name] = t
+	for _, alias := range strings.Split(t.Aliases, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			c.aliases[alias] = t.Name
+		}
+	}
+}
+
+// errUnknownActivity is returned by resolve when nothing matches.
+var errUnknownActivity = errors.New("unknown activity")
+
+// resolve returns the activity named by a choice number, a name, an alias or
+// a prefix of a name. A prefix of several names is an error.
+func (c *catalogue) resolve(input string) (string, error) {
+	if num, err := strconv.Atoi(input); err == nil && num > 0 && num <= len(c.types) {
+		return c.types[num-1].Name, nil
+	}
+	if _, ok := c.byName[input]; ok {
+		return input, nil
+	}
+	if name, ok := c.aliases[input]; ok {
+		return name, nil
+	}
+	var matches []string
+	for _, t := range c.types {
+		if strings.HasPrefix(t.Name, input) {
+			matches = append(matches, t.Name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", errUnknownActivity
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%q matches %s", input, strings.Join(matches, ", "))
+	}
+}
+
+// category returns the category of an activity, "other" when unknown.
+func (c *catalogue) category(activity string) string {
+	if t, ok := c.byName[activity]; ok && t.Category != "" {
+		return t.Category
+	}
+	return "other"
+}
+
+// colorize wraps text in the color of the activity, unless NO_COLOR is set.
+func (c *catalogue) colorize(activity, text string) string {
+	code, ok := ansiColors[c.byName[activity].Color]
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// timeline renders the day as one character per slot, each colored after the
+// activity covering most of it.
+func (c *catalogue) timeline(trackers []domain.DailyTracker, dayStart time.Time, slot time.Duration) string {
+	var b strings.Builder
+	for start := dayStart; start.Before(dayStart.Add(24 * time.Hour)); start = start.Add(slot) {
+		end := start.Add(slot)
+		best, bestCover := "", time.Duration(0)
+		for _, tracker := range trackers {
+			trackerEnd := domain.Now()
+			if tracker.EndTime != nil {
+				trackerEnd = *tracker.EndTime
+			}
+			from, to := tracker.StartTime, trackerEnd
+			if from.Before(start) {
+				from = start
+			}
+			if to.After(end) {
+				to = end
+			}
+			if cover := to.Sub(from); cover > bestCover {
+				best, bestCover = tracker.Activity, cover
+			}
+		}
+		if best == "" {
+			b.WriteString("·")
+			continue
+		}
+		b.WriteString(c.colorize(best, "█"))
+	}
+	return b.String()
+}
+
+// timelineAxis labels every third hour of a timeline of 30 minute slots.
+func timelineAxis(dayStart time.Time) string {
+	var b strings.Builder
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&b, "%-6d", dayStart.Add(time.Duration(3*i)*time.Hour).Hour())
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// promptActivity asks for an activity of the catalogue until a known one is
+// given or a new one is confirmed and added to the catalogue.
+func promptActivity(reader *bufio.Reader) (string, error) {
+	c, err := loadCatalogue()
+	if err != nil {
+		return "", err
+	}
+
+	for index, t := range c.types {
+		fmt.Printf("%d:%s, ", index+1, c.colorize(t.Name, t.Name))
+	}
+
+	for {
+		input, err := reader.ReadString('\n')
+		input = strings.TrimSpace(input)
+		if input == "" {
+			if err != nil {
+				return "", fmt.Errorf("no activity given")
+			}
+			fmt.Print("activity: ")
+			continue
+		}
+
+		name, err := c.resolve(input)
+		if err == nil {
+			return name, nil
+		}
+		if err != errUnknownActivity {
+			fmt.Printf("%v, activity: ", err)
+			continue
+		}
+
+		fmt.Printf("%q is not in the catalogue, enter its category to add it or leave blank to retry: ", input)
+		category, _ := reader.ReadString('\n')
+		category = strings.TrimSpace(category)
+		if category == "" {
+			fmt.Print("activity: ")
+			continue
+		}
+		if err := db.SaveActivityType(domain.ActivityType{Name: input, Category: category}); err != nil {
+			return "", fmt.Errorf("error saving activity %s: %v", input, err)
+		}
+		return input, nil
+	}
+}
+
+func listActivityTypes() error {
+	c, err := loadCatalogue()
+	if err != nil {
+		return err
+	}
+	for _, t := range c.types {
+		fmt.Printf("%s %-10s %-8s %s\n", c.colorize(t.Name, fmt.Sprintf("%-20s", t.Name)), c.category(t.Name), t.Color, t.Aliases)
+	}
+	return nil
+}
+
+func defineActivityType(args []string) error {
+	flags := flag.NewFlagSet("define", flag.ContinueOnError)
+	category := flags.String("category", "", "category such as work, rest, sleep or leisure")
+	color := flags.String("color", "", "color: black, red, green, yellow, blue, magenta, cyan or white")
+	aliases := flags.String("alias", "", "comma separated aliases")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: pomo activity define [-category c] [-color c] [-alias a,b] <name>")
+	}
+	if _, ok := ansiColors[*color]; *color != "" && !ok {
+		return fmt.Errorf("unknown color %q", *color)
+	}
+
+	types, err := db.GetActivityTypes()
+	if err != nil {
+		return fmt.Errorf("error fetching activity types: %v", err)
+	}
+	activityType := domain.ActivityType{Name: flags.Arg(0)}
+	for _, t := range types {
+		if t.Name == activityType.Name {
+			activityType = t
+		}
+	}
+
+	if *category != "" {
+		activityType.Category = *category
+	}
+	if *color != "" {
+		activityType.Color = *color
+	}
+	if *aliases != "" {
+		activityType.Aliases = *aliases
+	}
+	if err := db.SaveActivityType(activityType); err != nil {
+		return fmt.Errorf("error saving activity %s: %v", activityType.Name, err)
+	}
+	fmt.Printf("defined %s (%s)\n", activityType.Name, activityType.Category)
+	return nil
+}
+
+// mergeAliases joins comma separated alias lists, leaving out duplicates and
+// the name of the activity itself.
+func mergeAliases(name string, lists ...string) string {
+	seen := map[string]bool{name: true}
+	var aliases []string
+	for _, list := range lists {
+		for _, alias := range strings.Split(list, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" && !seen[alias] {
+				seen[alias] = true
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	return strings.Join(aliases, ",")
+}
+
+// renameActivity merges a misspelled activity into another one, in the
+// history and the catalogue, keeping the old name and its aliases as
+// aliases.
+func renameActivity(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: pomo activity rename <old> <new>")
+	}
+	from, to := args[0], args[1]
+	if from == to {
+		return fmt.Errorf("cannot rename %s to itself", from)
+	}
+
+	types, err := db.GetActivityTypes()
+	if err != nil {
+		return fmt.Errorf("error fetching activity types: %v", err)
+	}
+	var source domain.ActivityType
+	target := domain.ActivityType{Name: to}
+	found := false
+	for _, t := range types {
+		switch t.Name {
+		case from:
+			source = t
+		case to:
+			target, found = t, true
+		}
+	}
+	// a new activity takes over the category and color of the old one
+	if !found {
+		target.Category, target.Color = source.Category, source.Color
+	}
+
+	renamed, err := db.RenameActivity(from, to)
+	if err != nil {
+		return fmt.Errorf("error renaming %s: %v", from, err)
+	}
+
+	target.Aliases = mergeAliases(to, target.Aliases, from, source.Aliases)
+	if err := db.SaveActivityType(target); err != nil {
+		return fmt.Errorf("error saving activity %s: %v", to, err)
+	}
+	fmt.Printf("renamed %d segments from %s to %s\n", renamed, from, to)
+	return nil
+}
@@ -0,0 +1,50 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/atony2099/pomo/domain"
+)
+
+func TestResolve(t *testing.T) {
+	c := &catalogue{byName: make(map[string]domain.ActivityType), aliases: make(map[string]string)}
+	c.add(domain.ActivityType{Name: "study", Aliases: "learn"})
+	c.add(domain.ActivityType{Name: "stretch"})
+	c.add(domain.ActivityType{Name: "walk"})
+
+	tests := []struct {
+		input     string
+		want      string
+		ambiguous bool
+	}{
+		{input: "2", want: "stretch"},
+		{input: "walk", want: "walk"},
+		{input: "learn", want: "study"},
+		{input: "stu", want: "study"},
+		{input: "st", ambiguous: true},
+		{input: "run"},
+	}
+
+	for _, tt := range tests {
+		got, err := c.resolve(tt.input)
+		switch {
+		case tt.ambiguous:
+			if err == nil || err == errUnknownActivity {
+				t.Errorf("resolve(%q) = %q, %v, want an ambiguity error", tt.input, got, err)
+			}
+		case tt.want == "":
+			if err != errUnknownActivity {
+				t.Errorf("resolve(%q) = %q, %v, want errUnknownActivity", tt.input, got, err)
+			}
+		case got != tt.want || err != nil:
+			t.Errorf("resolve(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestMergeAliases(t *testing.T) {
+	got := mergeAliases("study", "learn, revise", "stdy", "learn,study,read")
+	if want := "learn,revise,stdy,read"; got != want {
+		t.Errorf("mergeAliases = %q, want %q", got, want)
+	}
+}
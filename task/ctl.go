@@ -0,0 +1,77 @@
+package task
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const ctlUsage = `usage: pomo ctl <command>
+
+  start
+  pause
+  resume
+  stop
+  extend
+  status
+  select-task <task-id>`
+
+// daemonTimeout bounds a call to the daemon, so clients fall back quickly
+// when none is running.
+const daemonTimeout = 2 * time.Second
+
+// daemonCall sends req to the daemon listening on socket and returns its
+// answer.
+func daemonCall(socket string, req daemonRequest) (daemonResponse, error) {
+	conn, err := net.DialTimeout("unix", socket, daemonTimeout)
+	if err != nil {
+		return daemonResponse{}, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(daemonTimeout))
+
+	data, _ := json.Marshal(req)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return daemonResponse{}, err
+	}
+
+	var resp daemonResponse
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return daemonResponse{}, err
+		}
+		return daemonResponse{}, errors.New("daemon closed the connection")
+	}
+	err = json.Unmarshal(scanner.Bytes(), &resp)
+	return resp, err
+}
+
+// CtlCommand runs `pomo ctl <command>`, which sends a command to the daemon
+// and prints its JSON answer, for scripts.
+func CtlCommand(args []string, socket string) {
+	if len(args) == 0 {
+		fmt.Println(ctlUsage)
+		return
+	}
+
+	req := daemonRequest{Command: args[0]}
+	if req.Command == CommandSelectTask {
+		if len(args) != 2 {
+			fmt.Println(ctlUsage)
+			return
+		}
+		req.TaskID = args[1]
+	}
+
+	resp, err := daemonCall(socket, req)
+	if err != nil {
+		fmt.Printf("Error calling daemon on %s: %v\n", socket, err)
+		return
+	}
+	data, _ := json.Marshal(resp)
+	fmt.Println(string(data))
+}
@@ -0,0 +1,458 @@
+package task
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/atony2099/pomo/audio"
+	"github.com/atony2099/pomo/cache"
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// Commands of the daemon control socket.
+const (
+	CommandStart      = "start"
+	CommandPause      = "pause"
+	CommandResume     = "resume"
+	CommandStop       = "stop"
+	CommandExtend     = "extend"
+	CommandStatus     = "status"
+	CommandSelectTask = "select-task"
+)
+
+// daemonRequest is a line sent to the control socket.
+type daemonRequest struct {
+	Command string `json:"command"`
+	// TaskID is the task to select with select-task.
+	TaskID string `json:"task_id,omitempty"`
+	// Reconciles is set by the clients that reconcile the day themselves
+	// after stopping a pomodoro, which the daemon then leaves to them.
+	Reconciles bool `json:"reconciles,omitempty"`
+}
+
+// daemonResponse is the line answering a request, with the session as it is
+// after the command.
+type daemonResponse struct {
+	OK      bool          `json:"ok"`
+	Error   string        `json:"error,omitempty"`
+	Session cache.Session `json:"session"`
+}
+
+// daemon owns the timer of the session shared by its clients. A session goes
+// from idle to focus, which can be paused and resumed, and once completed to
+// a break, back to idle when the break is over or anything is stopped.
+//
+// mu only guards the state: the database, cache and hook work of a change is
+// queued in pending and done once mu is released, so that a slow redis or
+// database never holds up the timer and the other clients.
+type daemon struct {
+	h *TaskHandler
+
+	mu    sync.Mutex
+	phase string
+	start time.Time
+	end   time.Time
+	// left is the focus time left while paused.
+	left  time.Duration
+	timer *time.Timer
+	// gen tells the timer of the current phase from the stopped ones.
+	gen     int
+	pending []func()
+	// seq numbers the published sessions.
+	seq int
+
+	// publishMu keeps the published sessions in order, published being the
+	// last one in the cache.
+	publishMu sync.Mutex
+	published int
+
+	// completing waits for the reconciliations started after the pomodoros.
+	completing sync.WaitGroup
+}
+
+// handle runs a command and returns the session after it.
+func (d *daemon) handle(req daemonRequest) daemonResponse {
+	// reading and selecting the task is I/O, done before taking the lock
+	var err error
+	switch req.Command {
+	case CommandStart:
+		_, err = selectedTaskID()
+	case CommandSelectTask:
+		err = d.selectTask(req.TaskID)
+	}
+
+	d.mu.Lock()
+	if err == nil {
+		switch req.Command {
+		case CommandStart:
+			err = d.startFocus()
+		case CommandPause:
+			err = d.pause()
+		case CommandResume:
+			err = d.resume()
+		case CommandStop:
+			err = d.stop(!req.Reconciles)
+		case CommandExtend:
+			err = d.extend()
+		case CommandStatus:
+		case CommandSelectTask:
+			d.publish()
+		default:
+			err = fmt.Errorf("unknown command %q", req.Command)
+		}
+	}
+	session := d.session()
+	d.unlock()
+
+	resp := daemonResponse{OK: err == nil, Session: withTask(session)}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// later queues f until mu is released.
+func (d *daemon) later(f func()) {
+	d.pending = append(d.pending, f)
+}
+
+// unlock releases mu and runs the work queued while it was held.
+func (d *daemon) unlock() {
+	pending := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+	for _, f := range pending {
+		f()
+	}
+}
+
+func (d *daemon) startFocus() error {
+	switch d.phase {
+	case cache.PhaseFocus, cache.PhasePaused:
+		return errors.New("a pomodoro is already running")
+	}
+	if d.phase != cache.PhaseIdle {
+		d.endBreak()
+	}
+	d.start = domain.Now()
+	d.enter(cache.PhaseFocus, d.h.pomodoroDuration)
+	d.runHooks(config.HookFocusStart, d.start, d.end, "", false)
+	return nil
+}
+
+func (d *daemon) pause() error {
+	if d.phase != cache.PhaseFocus {
+		return errors.New("no pomodoro to pause")
+	}
+	d.left = time.Until(d.end)
+	d.enter(cache.PhasePaused, 0)
+	return nil
+}
+
+func (d *daemon) resume() error {
+	if d.phase != cache.PhasePaused {
+		return errors.New("no paused pomodoro")
+	}
+	d.enter(cache.PhaseFocus, d.left)
+	return nil
+}
+
+// stop interrupts the pomodoro, saving it and reconciling the day if
+// reconcile is set, or ends the break.
+func (d *daemon) stop(reconcile bool) error {
+	switch d.phase {
+	case cache.PhaseIdle:
+		return errors.New("nothing to stop")
+	case cache.PhaseFocus, cache.PhasePaused:
+		d.finishFocus(domain.OutcomeInterrupted, reconcile)
+	default:
+		d.endBreak()
+	}
+	d.enter(cache.PhaseIdle, 0)
+	return nil
+}
+
+// extend makes the break longer by breakExtension.
+func (d *daemon) extend() error {
+	if d.phase != cache.PhaseBreak && d.phase != cache.PhaseLongBreak {
+		return errors.New("no break to extend")
+	}
+	d.enter(d.phase, time.Until(d.end)+breakExtension)
+	return nil
+}
+
+// selectTask selects the task in the cache. It runs without mu.
+func (d *daemon) selectTask(taskID string) error {
+	if taskID == "" {
+		return errors.New("no task id given")
+	}
+	task, err := db.GetTask(taskID)
+	if err != nil {
+		return fmt.Errorf("error getting task %s: %w", taskID, err)
+	}
+	selected := createSelectedTask(task, nil)
+	if task.ParentTaskID != "" {
+		parent, err := db.GetTask(task.ParentTaskID)
+		if err != nil {
+			return fmt.Errorf("error getting task %s: %w", task.ParentTaskID, err)
+		}
+		selected = createSelectedTask(parent, &task)
+	}
+	return cache.SetSelectedTask(selected)
+}
+
+// enter moves to phase, ending after duration, and publishes the session. Focus
+// and breaks end on their own, the other phases have no end.
+func (d *daemon) enter(phase string, duration time.Duration) {
+	d.phase = phase
+	d.gen++
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if phase == cache.PhaseFocus || phase == cache.PhaseBreak || phase == cache.PhaseLongBreak {
+		d.end = domain.Now().Add(duration)
+		gen := d.gen
+		d.timer = time.AfterFunc(duration, func() { d.expire(gen) })
+	}
+	d.publish()
+}
+
+// expire ends the phase the timer of generation gen was set for: a completed
+// pomodoro is followed by its break, a break by idle.
+func (d *daemon) expire(gen int) {
+	d.mu.Lock()
+	if gen != d.gen {
+		d.mu.Unlock()
+		return
+	}
+
+	if d.phase != cache.PhaseFocus {
+		go audio.PlaySound(audio.Breaks)
+		d.endBreak()
+		d.enter(cache.PhaseIdle, 0)
+		d.unlock()
+		return
+	}
+
+	d.finishFocus(domain.OutcomeCompleted, true)
+	d.start = domain.Now()
+	d.enter(cache.PhaseBreak, d.h.breakDuration)
+	gen = d.gen
+	// saves the pomodoro before counting them
+	d.unlock()
+
+	// the last pomodoro of a cycle is followed by a long break
+	completed, err := d.h.completedToday()
+	long := err == nil && d.h.cycleLength > 0 && completed%d.h.cycleLength == 0
+
+	d.mu.Lock()
+	// unless the break was stopped meanwhile
+	if gen == d.gen {
+		if long {
+			d.enter(cache.PhaseLongBreak, d.h.longBreak)
+		}
+		d.runHooks(config.HookBreakStart, d.start, d.end, "", long)
+	}
+	d.unlock()
+}
+
+// endBreak runs the break_end hooks of the running break.
+func (d *daemon) endBreak() {
+	d.runHooks(config.HookBreakEnd, d.start, domain.Now(), "", d.phase == cache.PhaseLongBreak)
+}
+
+// runHooks queues the hooks of event, which read the selected task.
+func (d *daemon) runHooks(event string, start, end time.Time, outcome string, long bool) {
+	d.later(func() { d.h.hooks.run(event, start, end, outcome, long) })
+}
+
+// finishFocus queues the saving of the running pomodoro with outcome, leaving
+// out the time it was paused, and the reconciliation of the day if reconcile
+// is set.
+func (d *daemon) finishFocus(outcome string, reconcile bool) {
+	start, now := d.start, domain.Now()
+	left := d.left
+	if d.phase == cache.PhaseFocus {
+		left = d.end.Sub(now)
+	}
+	focused := d.h.pomodoroDuration - left
+
+	event := config.HookFocusEnd
+	if outcome == domain.OutcomeInterrupted {
+		event = config.HookInterrupted
+	}
+	d.runHooks(event, start, now, outcome, false)
+
+	d.later(func() {
+		if focused <= d.h.stopInFirst {
+			fmt.Printf("pomo duration: %ds less than %v seconds, ignore it\n", focused/time.Second, d.h.stopInFirst)
+			return
+		}
+
+		if err := d.h.saveTimeEntry(context.Background(), start, now, focused, outcome); err != nil {
+			fmt.Printf("error posting data: %v\n", err)
+			return
+		}
+
+		sound := audio.Finish
+		if outcome == domain.OutcomeInterrupted {
+			sound = audio.Interrupt
+		}
+		go audio.PlaySound(sound)
+		if !reconcile {
+			return
+		}
+		// nobody answers prompts here, leave gaps for later
+		d.completing.Add(1)
+		go func() {
+			defer d.completing.Done()
+			Complete(0, CompleteOptions{Rules: d.h.rules, JournalDir: d.h.journalDir})
+		}()
+	})
+}
+
+// session returns the running session, without its task.
+func (d *daemon) session() cache.Session {
+	session := cache.Session{Phase: d.phase}
+	if d.phase == cache.PhaseIdle {
+		return session
+	}
+	session.Start, session.End = d.start, d.end
+	if d.phase == cache.PhasePaused {
+		session.End, session.Left = time.Time{}, d.left
+	}
+	return session
+}
+
+// withTask adds the selected task to a running session.
+func withTask(session cache.Session) cache.Session {
+	if session.Phase == cache.PhaseIdle {
+		return session
+	}
+	if task, err := cache.GetSelectedTask(); err == nil {
+		session.Task, session.Subtask, session.Project = task.Name, task.SubName, task.Project
+	}
+	return session
+}
+
+// publish queues the sharing of the session with the processes reading the
+// cache.
+func (d *daemon) publish() {
+	d.seq++
+	seq, session := d.seq, d.session()
+	d.later(func() { d.share(seq, session) })
+}
+
+// share writes the session numbered seq to the cache, unless a later one is
+// already there.
+func (d *daemon) share(seq int, session cache.Session) {
+	d.publishMu.Lock()
+	defer d.publishMu.Unlock()
+	if seq <= d.published {
+		return
+	}
+	d.published = seq
+
+	var err error
+	if session.Phase == cache.PhaseIdle {
+		err = cache.ClearSession()
+	} else {
+		err = cache.SetSession(withTask(session))
+	}
+	if err != nil {
+		fmt.Printf("Error publishing session: %v\n", err)
+	}
+}
+
+// webhookRetryInterval is how often the daemon retries failed webhooks.
+const webhookRetryInterval = time.Minute
+
+// retryWebhooks posts the due webhook deliveries every minute.
+func retryWebhooks() {
+	ticker := time.NewTicker(webhookRetryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		deliverWebhooks(os.Stdout)
+	}
+}
+
+// serve answers the requests of a client, one JSON object per line.
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req daemonRequest
+		resp := daemonResponse{Error: "invalid request"}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err == nil {
+			resp = d.handle(req)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// RunDaemon runs `pomo daemon`, which keeps the session until interrupted and
+// takes commands on the control socket.
+func RunDaemon(conf *config.Configuration) {
+	socket := conf.SocketPath()
+	if _, err := daemonCall(socket, daemonRequest{Command: CommandStatus}); err == nil {
+		fmt.Printf("a daemon is already running on %s\n", socket)
+		return
+	}
+	// a socket left behind by a killed daemon
+	_ = os.Remove(socket)
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		fmt.Printf("Error listening on %s: %v\n", socket, err)
+		return
+	}
+	defer os.Remove(socket)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	d := &daemon{h: NewTaskHandler(conf), phase: cache.PhaseIdle}
+	d.h.hooks.errors = os.Stdout
+	go retryWebhooks()
+	d.mu.Lock()
+	d.publish()
+	d.unlock()
+	fmt.Printf("pomo daemon listening on %s\n", socket)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			break
+		}
+		go d.serve(conn)
+	}
+
+	// a pomodoro cut short by the shutdown still counts
+	d.mu.Lock()
+	if d.phase != cache.PhaseIdle {
+		_ = d.stop(true)
+	}
+	d.unlock()
+	// an interrupted reconciliation would leave the day half written
+	d.completing.Wait()
+	fmt.Println("pomo daemon stopped")
+}
@@ -0,0 +1,124 @@
+// The dashboard reads the JSON API of `pomo serve`. Durations come as
+// nanoseconds.
+"use strict";
+
+const minute = 60e9;
+
+function duration(ns) {
+  const minutes = Math.round(ns / minute);
+  return Math.floor(minutes / 60) + "h" + String(minutes % 60).padStart(2, "0");
+}
+
+function clock(date) {
+  return new Date(date).toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" });
+}
+
+async function get(path) {
+  const resp = await fetch(path);
+  const body = await resp.json();
+  if (!resp.ok) {
+    throw new Error(body.error || resp.statusText);
+  }
+  return body;
+}
+
+function cell(text, className) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  if (className) {
+    td.className = className;
+  }
+  return td;
+}
+
+function fillTable(id, rows) {
+  const table = document.getElementById(id);
+  table.replaceChildren();
+  for (const row of rows) {
+    const tr = document.createElement("tr");
+    tr.append(...row);
+    table.append(tr);
+  }
+}
+
+async function showSession() {
+  const session = await get("/api/session");
+  const el = document.getElementById("session");
+  if (session.phase === "idle") {
+    el.textContent = "idle";
+    return;
+  }
+  let left = session.left / 1e9;
+  if (session.phase !== "paused") {
+    left = Math.max(0, (new Date(session.end) - Date.now()) / 1000);
+  }
+  const remaining = Math.floor(left / 60) + ":" + String(Math.floor(left % 60)).padStart(2, "0");
+  const task = session.subtask ? session.task + " › " + session.subtask : session.task;
+  el.textContent = session.phase.replace("_", " ") + " " + remaining + " " + task;
+}
+
+async function showToday() {
+  const review = await get("/api/reports/day");
+  const cards = [
+    ["pomodoros", review.Pomodoros],
+    ["focus", duration(review.Focus)],
+    ["breaks", duration(review.Breaks)],
+    ["distractions", duration(review.Distractions)],
+    ["7-day average", duration(review.AverageFocus)],
+  ];
+  const today = document.getElementById("today");
+  today.replaceChildren();
+  for (const [label, value] of cards) {
+    const card = document.createElement("div");
+    card.className = "card";
+    const b = document.createElement("b");
+    b.textContent = value;
+    card.append(b, label);
+    today.append(card);
+  }
+  fillTable("tasks", (review.Tasks || []).map((t) => [cell(t.Task), cell(duration(t.Focus), "num")]));
+}
+
+async function showWeek() {
+  const review = await get("/api/reports/week");
+  const days = review.Days || [];
+  const most = Math.max(minute, ...days.map((d) => d.Focus));
+  const week = document.getElementById("week");
+  week.replaceChildren();
+  for (const day of days) {
+    const bar = document.createElement("div");
+    bar.className = day.GoalMet ? "bar" : "bar missed";
+    bar.title = day.Pomodoros + " pomodoros, " + duration(day.Focus);
+    const fill = document.createElement("div");
+    fill.style.height = (day.Focus / most) * 100 + "%";
+    const label = new Date(day.Day).toLocaleDateString([], { weekday: "short" });
+    bar.append(fill, label);
+    week.append(bar);
+  }
+  fillTable("projects", (review.Projects || []).map((p) => [
+    cell(p.Project || "no project"),
+    cell(p.Pomodoros, "num"),
+    cell(duration(p.Focus), "num"),
+  ]));
+}
+
+async function showEntries() {
+  const entries = await get("/api/entries");
+  fillTable("entries", (entries || []).map((e) => [
+    cell(clock(e.StartTime) + "–" + clock(e.EndTime)),
+    cell(e.TaskName || e.TaskTitle || e.TaskID),
+    cell(e.ProjectName),
+    cell(e.Outcome),
+    cell(e.Note),
+  ]));
+}
+
+function refresh() {
+  for (const show of [showSession, showToday, showWeek, showEntries]) {
+    show().catch((err) => console.error(err));
+  }
+}
+
+refresh();
+setInterval(showSession, 1000);
+setInterval(refresh, 60 * 1000);
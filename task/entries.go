@@ -0,0 +1,130 @@
+package task
+
+import (
+	"time"
+
+	"github.com/atony2099/pomo/domain"
+)
+
+const dayLayout = "2006-01-02"
+
+// dayStartHour is the hour at which a day starts, so that the hours after
+// midnight can count toward the previous day.
+var dayStartHour int
+
+// SetDayStartHour sets the hour at which days start for tracking and reports.
+func SetDayStartHour(hour int) {
+	if hour >= 0 && hour < 24 {
+		dayStartHour = hour
+	}
+}
+
+// dayFocus is the focus recorded on a single day.
+type dayFocus struct {
+	Pomodoros int
+	Focus     time.Duration
+}
+
+// startOfDay returns the start of the day t falls in.
+func startOfDay(t time.Time) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), dayStartHour, 0, 0, 0, t.Location())
+	if t.Before(start) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// parseDay returns the start of the day named as 2006-01-02.
+func parseDay(day string) (time.Time, error) {
+	date, err := time.ParseInLocation(dayLayout, day, domain.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return date.Add(time.Duration(dayStartHour) * time.Hour), nil
+}
+
+// startOfWeek returns the start of the Monday of the week t falls in.
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// dayKey returns the day t is accounted to.
+func dayKey(t time.Time) string {
+	return startOfDay(t).Format(dayLayout)
+}
+
+// span is a part of a session or segment.
+type span struct {
+	Start time.Time
+	End   time.Time
+}
+
+// splitByDay cuts [start, end) into its portions on each day.
+func splitByDay(start, end time.Time) []span {
+	var spans []span
+	for start.Before(end) {
+		next := startOfDay(start).AddDate(0, 0, 1)
+		if next.After(end) {
+			next = end
+		}
+		spans = append(spans, span{start, next})
+		start = next
+	}
+	return spans
+}
+
+// clip returns the part of [start, end) within [from, to).
+func clip(start, end, from, to time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// focusByDay sums pomodoros and focus time per day. A session crossing the
+// start of a day counts toward both days with its portion on each, and as a
+// pomodoro on the day it started.
+func focusByDay(entries []domain.ProjectEntry) map[string]dayFocus {
+	days := make(map[string]dayFocus)
+	for _, entry := range entries {
+		for i, portion := range splitByDay(entry.StartTime, entry.EndTime) {
+			key := dayKey(portion.Start)
+			day := days[key]
+			if i == 0 {
+				day.Pomodoros++
+			}
+			day.Focus += portion.End.Sub(portion.Start)
+			days[key] = day
+		}
+	}
+	return days
+}
+
+// streaks walks the days from `from` to `to` and returns the current and the
+// longest run of consecutive days for which met returns true. A day `to` that
+// is not met yet does not break the current streak, as it is still in
+// progress.
+func streaks(from, to time.Time, met func(day string) bool) (current, longest int) {
+	run := 0
+	for day := startOfDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if met(dayKey(day)) {
+			run++
+			if run > longest {
+				longest = run
+			}
+			continue
+		}
+		if dayKey(day) != dayKey(to) {
+			run = 0
+		}
+	}
+	return run, longest
+}
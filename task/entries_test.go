@@ -0,0 +1,31 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atony2099/pomo/domain"
+)
+
+func TestDaysInDisplayZone(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	domain.SetLocation(tokyo)
+	defer domain.SetLocation(time.Local)
+
+	day, err := parseDay("2024-01-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 1, 10, 0, 0, 0, 0, tokyo); !day.Equal(want) {
+		t.Errorf("parseDay = %v, want %v", day, want)
+	}
+
+	// 20:00 UTC is already the next day in Tokyo
+	evening := time.Date(2024, 1, 10, 20, 0, 0, 0, time.UTC)
+	if got := dayKey(evening.In(domain.Location())); got != "2024-01-11" {
+		t.Errorf("dayKey = %s, want 2024-01-11", got)
+	}
+	if got := domain.Now().Location(); got != tokyo {
+		t.Errorf("Now is in %v, want %v", got, tokyo)
+	}
+}
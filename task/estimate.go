@@ -0,0 +1,169 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/atony2099/pomo/cache"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// estimatedPomodoros returns the estimate of a task in pomodoros. The local
+// estimate wins over the one synced from ClickUp.
+func estimatedPomodoros(task db.Task, pomodoroDuration time.Duration) int {
+	if task.Estimate > 0 {
+		return task.Estimate
+	}
+	if task.TimeEstimate <= 0 || pomodoroDuration <= 0 {
+		return 0
+	}
+	estimate := time.Duration(task.TimeEstimate) * time.Millisecond
+	return int((estimate + pomodoroDuration - 1) / pomodoroDuration)
+}
+
+// completedPomodoros counts the completed sessions among entries.
+func completedPomodoros(entries []domain.TimeEntry, pomodoroDuration time.Duration) int {
+	count := 0
+	for _, entry := range entries {
+		if entry.Completed(pomodoroDuration) {
+			count++
+		}
+	}
+	return count
+}
+
+// selectedTaskID returns the id of the task pomodoros are recorded against.
+func selectedTaskID() (string, error) {
+	task, err := cache.GetSelectedTask()
+	if err != nil {
+		return "", fmt.Errorf("error getting selected task: %w", err)
+	}
+	if task.SubID != "" {
+		return task.SubID, nil
+	}
+	if task.TaskID == "" {
+		return "", fmt.Errorf("no task selected")
+	}
+	return task.TaskID, nil
+}
+
+// SetEstimate sets the estimate in pomodoros of the selected task.
+func SetEstimate(pomodoros int) {
+	taskID, err := selectedTaskID()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	if err := db.SetTaskEstimate(taskID, pomodoros); err != nil {
+		fmt.Printf("Error setting estimate: %v\n", err)
+		return
+	}
+	fmt.Printf("Estimate set to %d pomodoros\n", pomodoros)
+}
+
+// EstimateStatus returns the remaining estimate of the selected task, or an
+// empty string when it has no estimate.
+func EstimateStatus(pomodoroDuration time.Duration) string {
+	taskID, err := selectedTaskID()
+	if err != nil {
+		return ""
+	}
+	task, err := db.GetTask(taskID)
+	if err != nil {
+		return ""
+	}
+	estimate := estimatedPomodoros(task, pomodoroDuration)
+	if estimate == 0 {
+		return ""
+	}
+	entries, err := db.SelectTaskEntries(taskID)
+	if err != nil {
+		return ""
+	}
+
+	done := completedPomodoros(entries, pomodoroDuration)
+	if done >= estimate {
+		return fmt.Sprintf("estimate %d/%d, %d over", done, estimate, done-estimate)
+	}
+	return fmt.Sprintf("estimate %d/%d, %d left", done, estimate, estimate-done)
+}
+
+// EstimateAccuracy compares the estimated and actual pomodoros of a task or
+// project.
+type EstimateAccuracy struct {
+	Name      string
+	Estimated int
+	Actual    int
+}
+
+// Ratio returns actual pomodoros per estimated one.
+func (a EstimateAccuracy) Ratio() float64 {
+	if a.Estimated == 0 {
+		return 0
+	}
+	return float64(a.Actual) / float64(a.Estimated)
+}
+
+// ComputeEstimates returns the estimate accuracy of every estimated task and
+// of every project having estimated tasks.
+func ComputeEstimates(pomodoroDuration time.Duration) (tasks, projects []EstimateAccuracy, err error) {
+	all, err := db.GetTasks()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving tasks: %w", err)
+	}
+
+	byProject := make(map[string]*EstimateAccuracy)
+	for _, task := range all {
+		estimate := estimatedPomodoros(task, pomodoroDuration)
+		if estimate == 0 {
+			continue
+		}
+		entries, err := db.SelectTaskEntries(task.TaskID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error selecting entries of task %s: %w", task.TaskID, err)
+		}
+
+		accuracy := EstimateAccuracy{Name: task.Name, Estimated: estimate, Actual: completedPomodoros(entries, pomodoroDuration)}
+		tasks = append(tasks, accuracy)
+
+		project, ok := byProject[task.ProjectName]
+		if !ok {
+			project = &EstimateAccuracy{Name: task.ProjectName}
+			byProject[task.ProjectName] = project
+		}
+		project.Estimated += accuracy.Estimated
+		project.Actual += accuracy.Actual
+	}
+
+	for _, project := range byProject {
+		projects = append(projects, *project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return tasks, projects, nil
+}
+
+// ShowEstimates prints the estimate versus actual report per task and project.
+func ShowEstimates(pomodoroTime int) {
+	tasks, projects, err := ComputeEstimates(time.Duration(pomodoroTime) * time.Minute)
+	if err != nil {
+		fmt.Printf("Error computing estimates: %v\n", err)
+		return
+	}
+
+	if len(tasks) == 0 {
+		fmt.Println("No estimated tasks")
+		return
+	}
+
+	fmt.Println("Tasks:")
+	for _, a := range tasks {
+		fmt.Printf("%-30s: %3d estimated, %3d actual, %3.0f%%\n", a.Name, a.Estimated, a.Actual, a.Ratio()*100)
+	}
+	fmt.Println("\nProjects:")
+	for _, a := range projects {
+		fmt.Printf("%-30s: %3d estimated, %3d actual, %3.0f%%\n", a.Name, a.Estimated, a.Actual, a.Ratio()*100)
+	}
+}
@@ -0,0 +1,186 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// priorityNames maps ClickUp priority names to their ids.
+var priorityNames = map[string]int{
+	"urgent": 1,
+	"high":   2,
+	"normal": 3,
+	"low":    4,
+}
+
+// TaskFilter narrows and orders the tasks offered by the task picker.
+type TaskFilter struct {
+	// Assignee keeps only the tasks assigned to this username.
+	Assignee string
+	// Due keeps only the tasks due "today", this "week" or "overdue".
+	Due string
+	// Priority keeps only the tasks at this priority or more urgent.
+	Priority string
+	// Tag keeps only the tasks having this tag.
+	Tag string
+	// Sort orders the tasks by "due" date or "priority".
+	Sort string
+}
+
+// Validate reports an unknown due, priority or sort value.
+func (f TaskFilter) Validate() error {
+	switch f.Due {
+	case "", "today", "week", "overdue":
+	default:
+		return fmt.Errorf("unknown due filter %q, use today, week or overdue", f.Due)
+	}
+	if _, ok := priorityNames[f.Priority]; f.Priority != "" && !ok {
+		return fmt.Errorf("unknown priority %q, use urgent, high, normal or low", f.Priority)
+	}
+	switch f.Sort {
+	case "", "due", "priority":
+	default:
+		return fmt.Errorf("unknown sort %q, use due or priority", f.Sort)
+	}
+	return nil
+}
+
+// match reports whether a single task passes the filter.
+func (f TaskFilter) match(task db.Task, now time.Time) bool {
+	if f.Assignee != "" && !containsName(task.Assignees, f.Assignee) {
+		return false
+	}
+	if f.Tag != "" && !containsName(task.Tags, f.Tag) {
+		return false
+	}
+	if f.Priority != "" && (task.Priority == 0 || task.Priority > priorityNames[f.Priority]) {
+		return false
+	}
+	if f.Due != "" {
+		if task.DueDate == nil {
+			return false
+		}
+		// today and week only hold their own calendar days, overdue tasks
+		// have a filter of their own
+		today := midnight(now)
+		var from, deadline time.Time
+		switch f.Due {
+		case "today":
+			from = today
+			deadline = from.AddDate(0, 0, 1)
+		case "week":
+			from = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+			deadline = from.AddDate(0, 0, 7)
+		case "overdue":
+			deadline = now
+		}
+		if task.DueDate.Before(from) || !task.DueDate.Before(deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the filtered and sorted tasks. A main task is kept when it or
+// one of its subtasks matches; the subtasks of a matching main task are all
+// kept.
+func (f TaskFilter) Apply(tasks []db.Task) []db.Task {
+	now := domain.Now()
+	keep := make(map[string]bool)
+	for _, task := range tasks {
+		if !f.match(task, now) {
+			continue
+		}
+		keep[task.TaskID] = true
+		if task.ParentTaskID != "" {
+			keep[task.ParentTaskID] = true
+		}
+	}
+	for _, task := range tasks {
+		if task.ParentTaskID != "" && f.match(findTask(tasks, task.ParentTaskID), now) {
+			keep[task.TaskID] = true
+		}
+	}
+
+	var filtered []db.Task
+	for _, task := range tasks {
+		if keep[task.TaskID] {
+			filtered = append(filtered, task)
+		}
+	}
+
+	switch f.Sort {
+	case "due":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return dueBefore(filtered[i].DueDate, filtered[j].DueDate)
+		})
+	case "priority":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return priorityRank(filtered[i].Priority) < priorityRank(filtered[j].Priority)
+		})
+	}
+	return filtered
+}
+
+func findTask(tasks []db.Task, taskID string) db.Task {
+	for _, task := range tasks {
+		if task.TaskID == taskID {
+			return task
+		}
+	}
+	return db.Task{}
+}
+
+// containsName reports whether the comma separated list holds name.
+func containsName(list, name string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// dueBefore orders tasks without a due date last.
+func dueBefore(a, b *time.Time) bool {
+	if a == nil {
+		return false
+	}
+	if b == nil {
+		return true
+	}
+	return a.Before(*b)
+}
+
+// priorityRank orders tasks without a priority last.
+func priorityRank(priority int) int {
+	if priority == 0 {
+		return len(priorityNames) + 1
+	}
+	return priority
+}
+
+// taskLabels describes the metadata of a task shown in the picker.
+func taskLabels(task db.Task) string {
+	var labels []string
+	for name, id := range priorityNames {
+		if id == task.Priority {
+			labels = append(labels, name)
+		}
+	}
+	if task.DueDate != nil {
+		labels = append(labels, "due "+task.DueDate.Format("01-02"))
+	}
+	if task.Tags != "" {
+		labels = append(labels, task.Tags)
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(labels, ", ") + "]"
+}
@@ -0,0 +1,193 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// streakWindow is how far back goal streaks are looked for.
+const streakWindow = 365
+
+// GoalProgress is the focus done toward a target within a period.
+type GoalProgress struct {
+	Name      string
+	Target    config.Target
+	Pomodoros int
+	Focus     time.Duration
+}
+
+// Met reports whether every part of the target has been reached.
+func (p GoalProgress) Met() bool {
+	return targetMet(p.Target, dayFocus{Pomodoros: p.Pomodoros, Focus: p.Focus})
+}
+
+func (p GoalProgress) String() string {
+	var parts []string
+	if p.Target.Pomodoros > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d pomodoros", p.Pomodoros, p.Target.Pomodoros))
+	}
+	if p.Target.Minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%dm", int(p.Focus.Minutes()), p.Target.Minutes))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ratio returns how much of the target is done, capped at 1.
+func (p GoalProgress) ratio() float64 {
+	ratio := 1.0
+	if p.Target.Pomodoros > 0 {
+		ratio = minFloat(ratio, float64(p.Pomodoros)/float64(p.Target.Pomodoros))
+	}
+	if p.Target.Minutes > 0 {
+		ratio = minFloat(ratio, p.Focus.Minutes()/float64(p.Target.Minutes))
+	}
+	return ratio
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func targetMet(target config.Target, day dayFocus) bool {
+	if !target.IsSet() {
+		return false
+	}
+	if target.Pomodoros > 0 && day.Pomodoros < target.Pomodoros {
+		return false
+	}
+	if target.Minutes > 0 && day.Focus < time.Duration(target.Minutes)*time.Minute {
+		return false
+	}
+	return true
+}
+
+// GoalReport is the progress toward every configured goal.
+type GoalReport struct {
+	Progress      []GoalProgress
+	CurrentStreak int
+	LongestStreak int
+}
+
+// ComputeGoals calculates the progress toward the configured goals as of now.
+func ComputeGoals(goals config.Goals, now time.Time) (GoalReport, error) {
+	today := startOfDay(now)
+	from := today.AddDate(0, 0, -streakWindow)
+	if week := startOfWeek(now); week.Before(from) {
+		from = week
+	}
+
+	until := today.AddDate(0, 0, 1)
+	entries, err := db.SelectEntriesWithProject(from, until)
+	if err != nil {
+		return GoalReport{}, fmt.Errorf("error selecting time entries: %w", err)
+	}
+
+	var report GoalReport
+	add := func(name string, target config.Target, entries []domain.ProjectEntry, since time.Time) {
+		if !target.IsSet() {
+			return
+		}
+		progress := GoalProgress{Name: name, Target: target}
+		for _, entry := range entries {
+			if !entry.StartTime.Before(since) {
+				progress.Pomodoros++
+			}
+			progress.Focus += clip(entry.StartTime, entry.EndTime, since, until)
+		}
+		report.Progress = append(report.Progress, progress)
+	}
+
+	add("today", goals.Daily, entries, today)
+	add("this week", goals.Weekly, entries, startOfWeek(now))
+
+	// viper lower-cases map keys, so projects are matched case-insensitively
+	projects := make([]string, 0, len(goals.Projects))
+	for name := range goals.Projects {
+		projects = append(projects, name)
+	}
+	sort.Strings(projects)
+	for _, name := range projects {
+		var projectEntries []domain.ProjectEntry
+		for _, entry := range entries {
+			if strings.EqualFold(entry.ProjectName, name) {
+				projectEntries = append(projectEntries, entry)
+			}
+		}
+		goal := goals.Projects[name]
+		add(name+" today", goal.Daily, projectEntries, today)
+		add(name+" this week", goal.Weekly, projectEntries, startOfWeek(now))
+	}
+
+	if goals.Daily.IsSet() {
+		days := focusByDay(entries)
+		report.CurrentStreak, report.LongestStreak = streaks(from, today, func(day string) bool {
+			return targetMet(goals.Daily, days[day])
+		})
+	}
+
+	return report, nil
+}
+
+// GoalSummary returns a one-line summary of the daily and weekly goals, or an
+// empty string when none are configured.
+func GoalSummary(goals config.Goals) string {
+	report, err := ComputeGoals(goals, domain.Now())
+	if err != nil {
+		return ""
+	}
+
+	var parts []string
+	for _, progress := range report.Progress {
+		if progress.Name == "today" || progress.Name == "this week" {
+			parts = append(parts, fmt.Sprintf("%s %s", progress.Name, progress))
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// ShowGoals prints the progress toward the configured goals.
+func ShowGoals(goals config.Goals) {
+	report, err := ComputeGoals(goals, domain.Now())
+	if err != nil {
+		fmt.Printf("Error computing goals: %v\n", err)
+		return
+	}
+
+	if len(report.Progress) == 0 {
+		fmt.Println("No goals configured")
+		return
+	}
+
+	for _, progress := range report.Progress {
+		mark := " "
+		if progress.Met() {
+			mark = "✓"
+		}
+		fmt.Printf("%s %-24s %s %s\n", mark, progress.Name, textBar(progress.ratio(), 20), progress)
+	}
+
+	if goals.Daily.IsSet() {
+		fmt.Printf("\nDaily goal streak: %d days (longest %d)\n", report.CurrentStreak, report.LongestStreak)
+	}
+}
+
+// textBar renders ratio as a bar of the given width.
+func textBar(ratio float64, width int) string {
+	filled := int(ratio * float64(width))
+	if filled > width {
+		filled = width
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	return "[" + strings.Repeat("█", filled) + strings.Repeat(" ", width-filled) + "]"
+}
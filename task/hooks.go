@@ -0,0 +1,99 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/atony2099/pomo/cache"
+)
+
+// hookTimeout is how long a hook may run before it is killed.
+const hookTimeout = 30 * time.Second
+
+// HookEvent is what hooks are told about a lifecycle event, as POMO_*
+// environment variables and as JSON on stdin.
+type HookEvent struct {
+	Event   string `json:"event"`
+	Task    string `json:"task"`
+	Subtask string `json:"subtask"`
+	Project string `json:"project"`
+	TaskID  string `json:"task_id"`
+	// Start and End are the times of the session or break, End being the
+	// planned end until it is over.
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Outcome string    `json:"outcome,omitempty"`
+	// Long is set for long breaks.
+	Long bool `json:"long,omitempty"`
+}
+
+// env returns the event as environment variables.
+func (e HookEvent) env() []string {
+	return []string{
+		"POMO_EVENT=" + e.Event,
+		"POMO_TASK=" + e.Task,
+		"POMO_SUBTASK=" + e.Subtask,
+		"POMO_PROJECT=" + e.Project,
+		"POMO_TASK_ID=" + e.TaskID,
+		"POMO_START=" + e.Start.Format(time.RFC3339),
+		"POMO_END=" + e.End.Format(time.RFC3339),
+		"POMO_OUTCOME=" + e.Outcome,
+		"POMO_LONG=" + strconv.FormatBool(e.Long),
+	}
+}
+
+// hooks runs the commands configured for lifecycle events.
+type hooks struct {
+	commands map[string][]string
+	// errors receives the failures of hooks. They are dropped when nil, as
+	// the terminal belongs to termbox.
+	errors io.Writer
+}
+
+// run starts the commands of event in the background with the selected task,
+// so that a slow hook never holds up the timer.
+func (h hooks) run(event string, start, end time.Time, outcome string, long bool) {
+	commands := h.commands[event]
+	if len(commands) == 0 {
+		return
+	}
+
+	e := HookEvent{Event: event, Start: start, End: end, Outcome: outcome, Long: long}
+	if task, err := cache.GetSelectedTask(); err == nil {
+		e.Task, e.Subtask, e.Project = task.Name, task.SubName, task.Project
+		e.TaskID = task.TaskID
+		if task.SubID != "" {
+			e.TaskID = task.SubID
+		}
+	}
+	data, _ := json.Marshal(e)
+
+	for _, command := range commands {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), e.env()...)
+		cmd.Stdin = bytes.NewReader(data)
+		if err := cmd.Start(); err != nil {
+			h.report(event, command, err)
+			continue
+		}
+		go func(command string) {
+			timer := time.AfterFunc(hookTimeout, func() { _ = cmd.Process.Kill() })
+			defer timer.Stop()
+			if err := cmd.Wait(); err != nil {
+				h.report(event, command, err)
+			}
+		}(command)
+	}
+}
+
+func (h hooks) report(event, command string, err error) {
+	if h.errors != nil {
+		fmt.Fprintf(h.errors, "Error running %s hook %q: %v\n", event, command, err)
+	}
+}
@@ -0,0 +1,49 @@
+package task
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// NoteCommand runs `pomo note [-entry id] <text>`, which attaches a note to
+// the last session, or to the given one. Without text it prints the note.
+func NoteCommand(args []string) {
+	flags := flag.NewFlagSet("note", flag.ContinueOnError)
+	entryID := flags.String("entry", "", "time entry to annotate instead of the last one")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+
+	var entry domain.TimeEntry
+	var err error
+	if *entryID != "" {
+		entry, err = db.GetTimeEntry(*entryID)
+	} else {
+		entry, err = db.SelectLatestTimeEntry()
+	}
+	if err != nil {
+		fmt.Printf("Error getting session: %v\n", err)
+		return
+	}
+
+	session := fmt.Sprintf("%s %s - %s", entry.TaskName, entry.StartTime.Format("2006-01-02 15:04"), entry.EndTime.Format("15:04"))
+	note := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if note == "" {
+		if entry.Note == "" {
+			fmt.Printf("%s has no note\n", session)
+			return
+		}
+		fmt.Printf("%s: %s\n", session, entry.Note)
+		return
+	}
+
+	if err := db.SetTimeEntryNote(entry.ID, note); err != nil {
+		fmt.Printf("Error saving note: %v\n", err)
+		return
+	}
+	fmt.Printf("noted %s\n", session)
+}
@@ -3,11 +3,13 @@ package task
 import (
 	"context"
 	"fmt"
+	"math/rand"
 
 	"time"
 
 	"github.com/atony2099/pomo/audio"
 	"github.com/atony2099/pomo/cache"
+	"github.com/atony2099/pomo/config"
 	"github.com/atony2099/pomo/db"
 	"github.com/atony2099/pomo/domain"
 	"github.com/atony2099/pomo/ui"
@@ -19,110 +21,260 @@ type TaskHandler struct {
 	stopInFirst      time.Duration
 	authKey          string
 	breakDuration    time.Duration
+	cycleLength      int
+	longBreak        time.Duration
+	breaks           config.Breaks
 	teamID           string
+	goals            config.Goals
+	rules            config.Rules
+	journalDir       string
+	socket           string
+	hooks            hooks
 }
 
-func NewTaskHandler(authKey, teamID string, pomodortime, invalidtime, breaktime int) *TaskHandler {
+func NewTaskHandler(conf *config.Configuration) *TaskHandler {
 	return &TaskHandler{
-		pomodoroDuration: time.Duration(pomodortime) * time.Minute,
-		stopInFirst:      time.Duration(invalidtime) * time.Second,
-		authKey:          authKey,
-		breakDuration:    time.Duration(breaktime) * time.Minute,
-		teamID:           teamID,
+		pomodoroDuration: time.Duration(conf.PomodoroTime) * time.Minute,
+		stopInFirst:      time.Duration(conf.StopInFirst) * time.Second,
+		authKey:          conf.AuthKey,
+		breakDuration:    time.Duration(conf.BreakTime) * time.Minute,
+		cycleLength:      conf.CycleLength,
+		longBreak:        time.Duration(conf.LongBreakTime) * time.Minute,
+		breaks:           conf.Breaks,
+		teamID:           conf.TeamID,
+		goals:            conf.Goals,
+		rules:            conf.Rules,
+		journalDir:       conf.JournalDir,
+		socket:           conf.SocketPath(),
+		hooks:            hooks{commands: conf.Hooks},
 	}
 }
 
-func listenForExit(exitChan chan bool) {
+// pollEvents forwards the terminal events. It outlives termbox.Close, so the
+// screen can be opened again for the break and the next pomodoro.
+func pollEvents(events chan<- termbox.Event) {
 	for {
-		switch ev := termbox.PollEvent(); ev.Type {
-		case termbox.EventKey:
-			if ev.Key == termbox.KeyEsc || ev.Key == termbox.KeySpace {
-				exitChan <- true
-			}
-		}
+		events <- termbox.PollEvent()
 	}
 }
 
+// RunPomodoro runs pomodoros, each followed by its break, until one is
+// stopped or a break ends without starting the next pomodoro. When a daemon
+// is running, the session is its own.
 func (h *TaskHandler) RunPomodoro() {
-	startTime := time.Now()
-	exitChan := make(chan bool, 1)
-	go listenForExit(exitChan)
+	events := make(chan termbox.Event)
+	go pollEvents(events)
+
+	if _, err := daemonCall(h.socket, daemonRequest{Command: CommandStatus}); err == nil {
+		h.runAttached(events)
+		return
+	}
+
+	for h.runSession(events) {
+		if err := termbox.Init(); err != nil {
+			fmt.Printf("Error initializing termbox: %v\n", err)
+			return
+		}
+	}
+}
+
+// runSession runs a single pomodoro and reports whether the next one was
+// asked for during the break.
+func (h *TaskHandler) runSession(events <-chan termbox.Event) bool {
+	startTime := domain.Now()
+
+	// the task, goals and estimates only change when a pomodoro is saved, so
+	// compute them once
+	countdown := h.countdown()
+	publishSession(cache.PhaseFocus, startTime, startTime.Add(h.pomodoroDuration))
+	h.hooks.run(config.HookFocusStart, startTime, startTime.Add(h.pomodoroDuration), "", false)
 
 	timerTick := time.NewTicker(1 * time.Second)
 	defer timerTick.Stop()
 
 	for {
 		select {
-		case <-exitChan:
-			h.finishPomodoro(startTime, time.Now(), audio.Interrupt, exitChan)
-			return
+		case ev := <-events:
+			switch {
+			case ev.Type == termbox.EventKey && (ev.Key == termbox.KeyEsc || ev.Key == termbox.KeySpace):
+				return h.finishPomodoro(startTime, domain.Now(), audio.Interrupt, events)
+			case ev.Type == termbox.EventResize:
+				countdown.Elapsed = time.Since(startTime)
+				ui.DrawCountdown(countdown)
+			}
 		case <-timerTick.C:
 			elapsed := time.Since(startTime)
 			if elapsed > h.pomodoroDuration {
-				h.finishPomodoro(startTime, time.Now(), audio.Finish, exitChan)
-				return
+				return h.finishPomodoro(startTime, domain.Now(), audio.Finish, events)
 			}
-			ui.DrawCountdownFull(h.pomodoroDuration, elapsed)
+			countdown.Elapsed = elapsed
+			ui.DrawCountdown(countdown)
 		}
 	}
 }
 
-func (task *TaskHandler) finishPomodoro(start, end time.Time, soundType audio.SoundType, exitChan chan bool) {
+// countdown returns the focus screen for the selected task and today's
+// sessions.
+func (h *TaskHandler) countdown() ui.Countdown {
+	countdown := ui.Countdown{
+		Total:  h.pomodoroDuration,
+		Cycle:  h.cycleLength,
+		Status: []string{GoalSummary(h.goals), EstimateStatus(h.pomodoroDuration)},
+	}
+
+	if task, err := cache.GetSelectedTask(); err == nil {
+		countdown.Task, countdown.Subtask, countdown.Project = task.Name, task.SubName, task.Project
+	}
+
+	now := domain.Now()
+	dayStart := startOfDay(now)
+	entries, err := db.SelectEntriesWithProject(dayStart, now)
+	if err != nil {
+		return countdown
+	}
+	today := focusByDay(entries)[dayKey(now)]
+	countdown.TodayPomodoros, countdown.TodayFocus = today.Pomodoros, today.Focus
+
+	// interrupted sessions do not move the cycle forward
+	completed, err := h.completedToday()
+	if err == nil && h.cycleLength > 0 {
+		countdown.Session = completed%h.cycleLength + 1
+	}
+	return countdown
+}
+
+// finishPomodoro saves the session and, when it ran its full length, runs the
+// break. It reports whether the next pomodoro should start.
+func (task *TaskHandler) finishPomodoro(start, end time.Time, soundType audio.SoundType, events <-chan termbox.Event) bool {
 	termbox.Close()
+	_ = cache.ClearSession()
+
+	outcome, event := domain.OutcomeCompleted, config.HookFocusEnd
+	if soundType == audio.Interrupt {
+		outcome, event = domain.OutcomeInterrupted, config.HookInterrupted
+	}
+	// hooks run even for sessions too short to keep, to undo focus_start
+	task.hooks.run(event, start, end, outcome, false)
 
 	if end.Sub(start) <= task.stopInFirst {
 		fmt.Printf("pomo duration: %ds less than %v seconds, ignore it\n", end.Sub(start)/time.Second, task.stopInFirst)
-		return
+		return false
 	}
 
 	// excute the sync task
 
-	err := task.saveTimeEntry(context.Background(), start, end)
+	err := task.saveTimeEntry(context.Background(), start, end, end.Sub(start), outcome)
 	if err != nil {
 		fmt.Printf("error posting data: %v\n", err)
-		return
+		return false
 	}
 
 	audio.PlaySound(soundType)
 
-	ui.ClearScreen()
-	if soundType == audio.Finish {
-		go func() {
-			// SyncData(task.authKey, task.teamID)
-			Complete(0)
-		}()
-		task.runBreakTimer(exitChan)
-	} else {
+	if soundType != audio.Finish {
+		ui.ClearScreen()
 		// SyncData(task.authKey, task.teamID)
-		Complete(0)
+		Complete(0, CompleteOptions{Interactive: true, Rules: task.rules, JournalDir: task.journalDir})
+		return false
 	}
 
+	if err := termbox.Init(); err != nil {
+		fmt.Printf("Error initializing termbox: %v\n", err)
+		return false
+	}
+	// the last pomodoro of a cycle is followed by a long break
+	completed, err := task.completedToday()
+	long := err == nil && task.cycleLength > 0 && completed%task.cycleLength == 0
+	next := task.runBreakTimer(events, long)
+	termbox.Close()
+
+	// SyncData(task.authKey, task.teamID)
+	// the break may be cut short by the next pomodoro, leave gaps for later
+	Complete(0, CompleteOptions{Rules: task.rules, JournalDir: task.journalDir})
+	return next
 }
 
-// runBreakTimer manages the break period after a pomodoro session.
-func (h *TaskHandler) runBreakTimer(exitChan chan bool) {
-	startTime := time.Now()
+// breakExtension is how much the e key adds to a break.
+const breakExtension = 5 * time.Minute
+
+// runBreakTimer shows the break screen until the break is over or skipped,
+// and reports whether the next pomodoro was asked for. Short breaks rotate
+// through the suggestions, long ones guide through the routine first.
+func (h *TaskHandler) runBreakTimer(events <-chan termbox.Event, long bool) bool {
+	breakScreen := ui.Break{Total: h.breakDuration, Long: long}
+	if long {
+		breakScreen.Total = h.longBreak
+	}
+	if task, err := cache.GetSelectedTask(); err == nil {
+		breakScreen.Task, breakScreen.Subtask = task.Name, task.SubName
+	}
+
+	// start the suggestions somewhere else every break
+	first := 0
+	if len(h.breaks.Suggestions) > 0 {
+		first = rand.Intn(len(h.breaks.Suggestions))
+	}
+
+	phase := cache.PhaseBreak
+	if long {
+		phase = cache.PhaseLongBreak
+	}
+	startTime := domain.Now()
+	publishSession(phase, startTime, startTime.Add(breakScreen.Total))
+	defer cache.ClearSession()
+	h.hooks.run(config.HookBreakStart, startTime, startTime.Add(breakScreen.Total), "", long)
+	defer func() { h.hooks.run(config.HookBreakEnd, startTime, domain.Now(), "", long) }()
+
+	draw := func() {
+		elapsed := time.Since(startTime)
+		breakScreen.Elapsed = elapsed
+		breakScreen.Guide = suggestion(h.breaks.Suggestions, first, elapsed)
+		if long {
+			if step, ok := routineStep(h.breaks.Routine, elapsed); ok {
+				breakScreen.Guide = step
+			}
+		}
+		ui.DrawBreak(breakScreen)
+	}
+	draw()
+
 	breakTicker := time.NewTicker(1 * time.Second)
 	defer breakTicker.Stop()
 
 	for {
 		select {
-		case <-exitChan:
-			fmt.Println("Break stopped early.")
-			audio.PlaySound(audio.Breaks)
-			return
+		case ev := <-events:
+			if ev.Type == termbox.EventResize {
+				draw()
+				continue
+			}
+			if ev.Type != termbox.EventKey {
+				continue
+			}
+			switch {
+			case ev.Ch == 's' || ev.Key == termbox.KeyEsc || ev.Key == termbox.KeySpace:
+				audio.PlaySound(audio.Breaks)
+				return false
+			case ev.Ch == 'e':
+				breakScreen.Total += breakExtension
+				publishSession(phase, startTime, startTime.Add(breakScreen.Total))
+				draw()
+			case ev.Ch == 'n' || ev.Key == termbox.KeyEnter:
+				return true
+			}
 		case <-breakTicker.C:
-			elapsed := time.Since(startTime)
-			ui.DrawProgressBar(elapsed.Seconds(), h.breakDuration.Seconds())
-			if elapsed > h.breakDuration {
+			if time.Since(startTime) > breakScreen.Total {
 				audio.PlaySound(audio.Breaks)
-				return
+				return false
 			}
+			draw()
 		}
 	}
 }
 
-func (h *TaskHandler) saveTimeEntry(ctx context.Context, start, end time.Time) error {
+// saveTimeEntry records a session of the selected task. actual is the time
+// focused, which excludes pauses.
+func (h *TaskHandler) saveTimeEntry(ctx context.Context, start, end time.Time, actual time.Duration, outcome string) error {
 
 	// get the selected task
 	task, err := cache.GetSelectedTask()
@@ -130,18 +282,22 @@ func (h *TaskHandler) saveTimeEntry(ctx context.Context, start, end time.Time) e
 		return fmt.Errorf("error getting selected task: %v", err)
 	}
 
-	taskID := task.TaskID
+	taskID, taskName := task.TaskID, task.Name
 	if task.SubID != "" {
-		taskID = task.SubID
+		taskID, taskName = task.SubID, task.SubName
 	}
 
 	// geneternage unique id
 	id := fmt.Sprintf("%s-%d", taskID, time.Now().UnixNano())
 	time := domain.TimeEntry{
-		ID:        id,
-		TaskID:    taskID,
-		StartTime: start,
-		EndTime:   end,
+		ID:              id,
+		TaskID:          taskID,
+		TaskName:        taskName,
+		StartTime:       start,
+		EndTime:         end,
+		Outcome:         outcome,
+		PlannedDuration: int64(h.pomodoroDuration.Seconds()),
+		ActualDuration:  int64(actual.Seconds()),
 	}
 
 	err = db.SaveTimeEntry(time)
@@ -150,6 +306,11 @@ func (h *TaskHandler) saveTimeEntry(ctx context.Context, start, end time.Time) e
 		return fmt.Errorf("error saving time entry: %v", err)
 	}
 
+	// the session is saved, a webhook failing to queue does not undo it
+	if err := queueTimeEntry(time, task.Project); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
 	return nil
 
 }
@@ -0,0 +1,255 @@
+package task
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// topTasks is how many tasks a day review lists.
+const topTasks = 3
+
+// TaskFocus is the focus spent on a task.
+type TaskFocus struct {
+	Task  string
+	Focus time.Duration
+}
+
+// DayReview summarizes a reconciled day.
+type DayReview struct {
+	Day          string
+	Pomodoros    int
+	Focus        time.Duration
+	Breaks       time.Duration
+	Distractions time.Duration
+	Untracked    time.Duration
+	Tasks        []TaskFocus
+	// AveragePomodoros and AverageFocus are the means over the seven
+	// previous days.
+	AveragePomodoros float64
+	AverageFocus     time.Duration
+}
+
+// BreakRatio returns the share of the pauses between sessions that were
+// breaks rather than distractions.
+func (r DayReview) BreakRatio() float64 {
+	if r.Breaks+r.Distractions == 0 {
+		return 0
+	}
+	return float64(r.Breaks) / float64(r.Breaks+r.Distractions)
+}
+
+// ComputeDayReview calculates the review of the day from its time entries and
+// segments. Breaks and distractions are the segments labelled by the break
+// rules.
+func ComputeDayReview(day string, rules []config.BreakRule) (DayReview, error) {
+	dayStart, trackedUntil, err := dayBounds(day)
+	if err != nil {
+		return DayReview{}, err
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	weekStart := dayStart.AddDate(0, 0, -7)
+
+	entries, err := db.SelectEntriesWithProject(weekStart, dayEnd)
+	if err != nil {
+		return DayReview{}, fmt.Errorf("error selecting time entries: %w", err)
+	}
+	trackers, err := selectDayTrackers(day)
+	if err != nil {
+		return DayReview{}, err
+	}
+
+	review := DayReview{Day: day}
+	days := focusByDay(entries)
+	review.Pomodoros = days[day].Pomodoros
+	review.Focus = days[day].Focus
+
+	var pomodoros int
+	var focus time.Duration
+	for d := weekStart; d.Before(dayStart); d = d.AddDate(0, 0, 1) {
+		pomodoros += days[dayKey(d)].Pomodoros
+		focus += days[dayKey(d)].Focus
+	}
+	review.AveragePomodoros = float64(pomodoros) / 7
+	review.AverageFocus = focus / 7
+
+	tasks := make(map[string]time.Duration)
+	for _, entry := range entries {
+		if portion := clip(entry.StartTime, entry.EndTime, dayStart, dayEnd); portion > 0 {
+			tasks[entry.Title()] += portion
+		}
+	}
+	for name, focus := range tasks {
+		review.Tasks = append(review.Tasks, TaskFocus{Task: name, Focus: focus})
+	}
+	sort.Slice(review.Tasks, func(i, j int) bool {
+		if review.Tasks[i].Focus != review.Tasks[j].Focus {
+			return review.Tasks[i].Focus > review.Tasks[j].Focus
+		}
+		return review.Tasks[i].Task < review.Tasks[j].Task
+	})
+	if len(review.Tasks) > topTasks {
+		review.Tasks = review.Tasks[:topTasks]
+	}
+
+	breaks := make(map[string]bool)
+	distractions := make(map[string]bool)
+	for _, rule := range rules {
+		breaks[rule.BreakLabel] = true
+		distractions[rule.DistractionLabel] = true
+	}
+	for _, tracker := range trackers {
+		if tracker.EndTime == nil {
+			continue
+		}
+		duration := clip(tracker.StartTime, *tracker.EndTime, dayStart, dayEnd)
+		if breaks[tracker.Activity] {
+			review.Breaks += duration
+		} else if distractions[tracker.Activity] {
+			review.Distractions += duration
+		}
+	}
+
+	for _, g := range findGaps(trackers, dayStart, trackedUntil) {
+		review.Untracked += g.End.Sub(g.Start)
+	}
+
+	return review, nil
+}
+
+// signedDuration formats d with an explicit sign, rounded to minutes.
+func signedDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + (-d).Round(time.Minute).String()
+	}
+	return "+" + d.Round(time.Minute).String()
+}
+
+// Markdown renders the review as a journal entry.
+func (r DayReview) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.Day)
+	fmt.Fprintf(&b, "- Focus: %v in %d pomodoros (%s vs the 7-day average of %v)\n",
+		r.Focus.Round(time.Minute), r.Pomodoros, signedDuration(r.Focus-r.AverageFocus), r.AverageFocus.Round(time.Minute))
+	fmt.Fprintf(&b, "- Breaks: %v, distractions: %v (%.0f%% breaks)\n",
+		r.Breaks.Round(time.Minute), r.Distractions.Round(time.Minute), r.BreakRatio()*100)
+	fmt.Fprintf(&b, "- Untracked: %v\n", r.Untracked.Round(time.Minute))
+	if len(r.Tasks) > 0 {
+		b.WriteString("\n## Top tasks\n\n")
+		for i, task := range r.Tasks {
+			fmt.Fprintf(&b, "%d. %s (%v)\n", i+1, task.Task, task.Focus.Round(time.Minute))
+		}
+	}
+	return b.String()
+}
+
+// ShowDayReview prints the review of the day offset days ago, and saves it
+// as <day>.md in journalDir unless it is empty.
+func ShowDayReview(offset int, rules []config.BreakRule, journalDir string) {
+	day := dayKey(domain.Now().AddDate(0, 0, -offset))
+	review, err := ComputeDayReview(day, rules)
+	if err != nil {
+		fmt.Printf("Error computing day review: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Review of %s:\n", review.Day)
+	fmt.Printf("%-20s: %v in %d pomodoros\n", "Focus", review.Focus.Round(time.Minute), review.Pomodoros)
+	fmt.Printf("%-20s: %v in %.1f pomodoros (%s today)\n", "7-day average", review.AverageFocus.Round(time.Minute), review.AveragePomodoros, signedDuration(review.Focus-review.AverageFocus))
+	fmt.Printf("%-20s: %v / %v (%.0f%% breaks)\n", "Breaks/distractions", review.Breaks.Round(time.Minute), review.Distractions.Round(time.Minute), review.BreakRatio()*100)
+	fmt.Printf("%-20s: %v\n", "Untracked", review.Untracked.Round(time.Minute))
+	for i, task := range review.Tasks {
+		fmt.Printf("%-20s: %s (%v)\n", fmt.Sprintf("Top task %d", i+1), task.Task, task.Focus.Round(time.Minute))
+	}
+
+	if journalDir == "" {
+		return
+	}
+	path, err := saveJournal(journalDir, review)
+	if err != nil {
+		fmt.Printf("Error saving journal: %v\n", err)
+		return
+	}
+	fmt.Printf("\nSaved to %s\n", path)
+}
+
+// saveJournal writes the review to <day>.md in dir, replacing the entry of a
+// day reconciled again.
+func saveJournal(dir string, review DayReview) (string, error) {
+	dir = os.ExpandEnv(dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, review.Day+".md")
+	return path, os.WriteFile(path, []byte(review.Markdown()), 0o644)
+}
+
+const reviewUsage = "usage: pomo review [days-ago]\n       pomo review -week [-format markdown|html] [-out file] [weeks-ago]"
+
+// ReviewCommand runs `pomo review`, which shows the review of a day, or with
+// -week writes the report of a week.
+func ReviewCommand(args []string, conf *config.Configuration) {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	week := flags.Bool("week", false, "report on a week instead of a day")
+	format := flags.String("format", "markdown", "week report format: markdown or html")
+	out := flags.String("out", "", "file to write the week report to, stdout by default")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+	if flags.NArg() > 1 {
+		fmt.Println(reviewUsage)
+		return
+	}
+
+	offset := 0
+	if flags.NArg() == 1 {
+		var err error
+		if offset, err = strconv.Atoi(flags.Arg(0)); err != nil || offset < 0 {
+			fmt.Printf("invalid offset %q\n", flags.Arg(0))
+			return
+		}
+	}
+
+	if !*week {
+		ShowDayReview(offset, conf.Rules.Breaks, conf.JournalDir)
+		return
+	}
+	if err := writeWeekReview(offset, conf.Goals, *format, *out); err != nil {
+		fmt.Printf("Error writing week review: %v\n", err)
+	}
+}
+
+// writeWeekReview writes the report of the week offset weeks ago to path,
+// or to stdout when path is empty.
+func writeWeekReview(offset int, goals config.Goals, format, path string) error {
+	if format != "markdown" && format != "html" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	review, err := ComputeWeekReview(startOfWeek(domain.Now()).AddDate(0, 0, -7*offset), goals)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if path != "" {
+		if out, err = os.Create(path); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	if format == "html" {
+		return review.WriteHTML(out)
+	}
+	return review.WriteMarkdown(out)
+}
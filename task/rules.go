@@ -0,0 +1,459 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// gap is untracked time between two segments of a day.
+type gap struct {
+	Start  time.Time
+	End    time.Time
+	After  string
+	Before string
+}
+
+func (g gap) String() string {
+	return fmt.Sprintf("[%s - %s], total %v", g.Start.Format("15:04:05"), g.End.Format("15:04:05"), g.End.Sub(g.Start))
+}
+
+// parseClock returns the time of day given as "hhmm" or "hh:mm" on the day of
+// base.
+func parseClock(base time.Time, clock string) (time.Time, error) {
+	clock = strings.Replace(strings.TrimSpace(clock), ":", "", 1)
+	if len(clock) != 4 {
+		return time.Time{}, fmt.Errorf("invalid time %q, use hhmm", clock)
+	}
+	hour, err := strconv.Atoi(clock[:2])
+	if err != nil || hour < 0 || hour > 23 {
+		return time.Time{}, fmt.Errorf("invalid hour in %q", clock)
+	}
+	minute, err := strconv.Atoi(clock[2:])
+	if err != nil || minute < 0 || minute > 59 {
+		return time.Time{}, fmt.Errorf("invalid minute in %q", clock)
+	}
+	return time.Date(base.Year(), base.Month(), base.Day(), hour, minute, 0, 0, base.Location()), nil
+}
+
+// parseClockFrom returns the first time at or after from with the given
+// "hhmm" time of day.
+func parseClockFrom(from time.Time, clock string) (time.Time, error) {
+	t, err := parseClock(from, clock)
+	if err != nil {
+		return t, err
+	}
+	if t.Before(from) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// midnight returns the calendar midnight of the date t falls on.
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dayBounds returns the start of the day and the time up to which it can be
+// tracked: now for today, the start of the next day otherwise.
+func dayBounds(day string) (time.Time, time.Time, error) {
+	start, err := parseDay(day)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end := start.AddDate(0, 0, 1)
+	if day == dayKey(domain.Now()) {
+		end = domain.Now().Truncate(time.Minute)
+	}
+	return start, end, nil
+}
+
+// selectDayTrackers returns the segments overlapping the day.
+func selectDayTrackers(day string) ([]domain.DailyTracker, error) {
+	start, err := parseDay(day)
+	if err != nil {
+		return nil, err
+	}
+	trackers, err := db.SelectDailyTracker(start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("error selecting daily tracker from day: %v", err)
+	}
+	return trackers, nil
+}
+
+// findGaps returns the untracked time between dayStart and dayEnd given the
+// segments sorted by start time. Gaps of a minute or less are ignored. An
+// open segment of a past day hides everything after it, as its end is
+// unknown; on the current day it runs until now.
+func findGaps(trackers []domain.DailyTracker, dayStart, dayEnd time.Time) []gap {
+	var gaps []gap
+	lastEnd := dayStart
+	lastActivity := ""
+	for _, tracker := range trackers {
+		if tracker.StartTime.After(lastEnd) && tracker.StartTime.Sub(lastEnd) > time.Minute {
+			gaps = append(gaps, gap{Start: lastEnd, End: tracker.StartTime, After: lastActivity, Before: tracker.Activity})
+		}
+
+		end := dayEnd
+		if tracker.EndTime != nil {
+			end = *tracker.EndTime
+		} else if dayKey(dayStart) != dayKey(domain.Now()) {
+			return gaps
+		}
+		if end.After(lastEnd) {
+			lastEnd = end
+		}
+		lastActivity = tracker.Activity
+	}
+
+	if dayEnd.Sub(lastEnd) > time.Minute {
+		gaps = append(gaps, gap{Start: lastEnd, End: dayEnd, After: lastActivity})
+	}
+	return gaps
+}
+
+// ruleSpans returns the parts of g the rule labels, nil when it does not
+// apply.
+func ruleSpans(rule config.GapRule, g gap) ([][2]time.Time, error) {
+	length := g.End.Sub(g.Start)
+	if rule.After != "" && rule.After != g.After {
+		return nil, nil
+	}
+	if rule.Before != "" && rule.Before != g.Before {
+		return nil, nil
+	}
+	if rule.MinGap > 0 && length < rule.MinGap {
+		return nil, nil
+	}
+	if rule.MaxGap > 0 && length > rule.MaxGap {
+		return nil, nil
+	}
+	if rule.From == "" && rule.To == "" {
+		return [][2]time.Time{{g.Start, g.End}}, nil
+	}
+
+	// a day starting after midnight spans two calendar dates
+	var windows [][2]time.Time
+	for _, date := range []time.Time{midnight(g.Start), midnight(g.Start).AddDate(0, 0, 1)} {
+		dateWindows, err := ruleWindows(rule, date)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, dateWindows...)
+	}
+	var spans [][2]time.Time
+	for _, window := range windows {
+		start, end := window[0], window[1]
+		if g.Start.After(start) {
+			start = g.Start
+		}
+		if g.End.Before(end) {
+			end = g.End
+		}
+		if end.Sub(start) >= time.Minute {
+			spans = append(spans, [2]time.Time{start, end})
+		}
+	}
+	return spans, nil
+}
+
+// ruleWindows returns the windows of the rule within the calendar date.
+func ruleWindows(rule config.GapRule, day time.Time) ([][2]time.Time, error) {
+	from, to := day, day.Add(24*time.Hour)
+	var err error
+	if rule.From != "" {
+		if from, err = parseClock(day, rule.From); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Activity, err)
+		}
+	}
+	if rule.To != "" {
+		if to, err = parseClock(day, rule.To); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Activity, err)
+		}
+	}
+	if to.After(from) {
+		return [][2]time.Time{{from, to}}, nil
+	}
+	// the window spans midnight
+	return [][2]time.Time{{day, to}, {from, day.Add(24 * time.Hour)}}, nil
+}
+
+// reconciliation holds the segments of the day being reconciled. Segments
+// are added through add, so that a dry run reports them instead of saving.
+type reconciliation struct {
+	start    time.Time
+	end      time.Time
+	trackers []domain.DailyTracker
+	dryRun   bool
+}
+
+func newReconciliation(day string, dryRun bool) (*reconciliation, error) {
+	start, end, err := dayBounds(day)
+	if err != nil {
+		return nil, err
+	}
+	trackers, err := selectDayTrackers(day)
+	if err != nil {
+		return nil, err
+	}
+	return &reconciliation{start: start, end: end, trackers: trackers, dryRun: dryRun}, nil
+}
+
+// add saves a segment unless the same one, with the same start, activity and
+// time entry, already exists. Other segments starting at the same time are
+// left to resolveOverlaps.
+func (r *reconciliation) add(tracker domain.DailyTracker) error {
+	for _, existing := range r.trackers {
+		if existing.StartTime.Equal(tracker.StartTime) && existing.Activity == tracker.Activity && existing.TimeEntryID == tracker.TimeEntryID {
+			return nil
+		}
+	}
+
+	if err := r.save(&tracker, "insert"); err != nil {
+		return err
+	}
+	r.trackers = append(r.trackers, tracker)
+	r.sort()
+	return nil
+}
+
+func (r *reconciliation) sort() {
+	sort.SliceStable(r.trackers, func(i, j int) bool {
+		return r.trackers[i].StartTime.Before(r.trackers[j].StartTime)
+	})
+}
+
+// save creates or updates a segment, or only reports it in a dry run.
+func (r *reconciliation) save(tracker *domain.DailyTracker, action string) error {
+	if r.dryRun {
+		fmt.Printf("would %s %-20s: %s - %s\n", action, tracker.Activity, tracker.StartTime.Format("15:04:05"), formatEnd(tracker.EndTime))
+		return nil
+	}
+	if err := db.SaveDailyTracker(tracker); err != nil {
+		return fmt.Errorf("error saving daily tracker: %v", err)
+	}
+	return nil
+}
+
+// remove deletes the segment at index i.
+func (r *reconciliation) remove(i int) error {
+	tracker := r.trackers[i]
+	if r.dryRun {
+		fmt.Printf("would delete %-20s: %s - %s\n", tracker.Activity, tracker.StartTime.Format("15:04:05"), formatEnd(tracker.EndTime))
+	} else if err := db.DeleteDailyTracker(tracker.ID); err != nil {
+		return fmt.Errorf("error deleting daily tracker %d: %v", tracker.ID, err)
+	}
+	r.trackers = append(r.trackers[:i], r.trackers[i+1:]...)
+	return nil
+}
+
+// upsertEntry creates or updates the segment linked to a time entry. A
+// segment saved before segments were linked is adopted when it has the same
+// activity and start time.
+func (r *reconciliation) upsertEntry(tracker domain.DailyTracker) error {
+	match := -1
+	for i, existing := range r.trackers {
+		if existing.TimeEntryID == tracker.TimeEntryID {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		for i, existing := range r.trackers {
+			if existing.TimeEntryID == "" && existing.Activity == tracker.Activity && existing.StartTime.Equal(tracker.StartTime) {
+				match = i
+				break
+			}
+		}
+	}
+
+	if match < 0 {
+		if err := r.save(&tracker, "insert"); err != nil {
+			return err
+		}
+		r.trackers = append(r.trackers, tracker)
+		r.sort()
+		return nil
+	}
+
+	existing := &r.trackers[match]
+	if existing.TimeEntryID == tracker.TimeEntryID && existing.StartTime.Equal(tracker.StartTime) &&
+		existing.EndTime != nil && existing.EndTime.Equal(*tracker.EndTime) {
+		return nil
+	}
+	existing.TimeEntryID = tracker.TimeEntryID
+	existing.StartTime = tracker.StartTime
+	existing.EndTime = tracker.EndTime
+	if err := r.save(existing, "update"); err != nil {
+		return err
+	}
+	r.sort()
+	return nil
+}
+
+// removeStale deletes the segments linked to time entries that no longer
+// exist on the day.
+func (r *reconciliation) removeStale(entryIDs map[string]bool) error {
+	for i := len(r.trackers) - 1; i >= 0; i-- {
+		if id := r.trackers[i].TimeEntryID; id != "" && !entryIDs[id] {
+			if err := r.remove(i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// overlapping reports whether cur, starting at or after prev, starts before
+// prev ends.
+func overlapping(prev, cur domain.DailyTracker) bool {
+	return prev.EndTime != nil && cur.StartTime.Before(*prev.EndTime)
+}
+
+// resolveOverlaps resolves the overlaps of segments of the same activity
+// when at most one of them comes from a time entry, and returns the other
+// overlaps for the user to fix.
+func (r *reconciliation) resolveOverlaps() ([][2]domain.DailyTracker, error) {
+	for {
+		resolved, err := r.resolveOverlap()
+		if err != nil {
+			return nil, err
+		}
+		if !resolved {
+			break
+		}
+	}
+
+	var flagged [][2]domain.DailyTracker
+	for i := 1; i < len(r.trackers); i++ {
+		if prev, cur := r.trackers[i-1], r.trackers[i]; overlapping(prev, cur) {
+			flagged = append(flagged, [2]domain.DailyTracker{prev, cur})
+		}
+	}
+	return flagged, nil
+}
+
+// resolveOverlap resolves the first overlap it can, and reports whether there
+// was one. Two segments entered by hand are merged. A segment entered by hand
+// is trimmed around one linked to a time entry, which keeps the bounds of its
+// entry so that the next reconciliation leaves both alone.
+func (r *reconciliation) resolveOverlap() (bool, error) {
+	for i := 1; i < len(r.trackers); i++ {
+		prev, cur := r.trackers[i-1], r.trackers[i]
+		if !overlapping(prev, cur) || prev.Activity != cur.Activity || (prev.TimeEntryID != "" && cur.TimeEntryID != "") {
+			continue
+		}
+
+		switch {
+		case prev.TimeEntryID != "":
+			return true, r.trimAround(i, i-1)
+		case cur.TimeEntryID != "":
+			return true, r.trimAround(i-1, i)
+		}
+
+		merged := &r.trackers[i-1]
+		if cur.EndTime == nil {
+			merged.EndTime = nil
+		} else if cur.EndTime.After(*prev.EndTime) {
+			merged.EndTime = cur.EndTime
+		}
+		if err := r.save(merged, "merge into"); err != nil {
+			return false, err
+		}
+		return true, r.remove(i)
+	}
+	return false, nil
+}
+
+// trimAround cuts the segment entered by hand at manual to the parts outside
+// the linked segment at linked, splitting it when it covers both sides and
+// deleting it when nothing is left.
+func (r *reconciliation) trimAround(manual, linked int) error {
+	m, l := r.trackers[manual], r.trackers[linked]
+	before := m.StartTime.Before(l.StartTime)
+	after := l.EndTime != nil && (m.EndTime == nil || m.EndTime.After(*l.EndTime))
+	if !before && !after {
+		return r.remove(manual)
+	}
+
+	trimmed := &r.trackers[manual]
+	if before {
+		linkedStart := l.StartTime
+		trimmed.EndTime = &linkedStart
+	} else {
+		trimmed.StartTime = *l.EndTime
+	}
+	if err := r.save(trimmed, "trim"); err != nil {
+		return err
+	}
+
+	if before && after {
+		rest := domain.DailyTracker{Activity: m.Activity, StartTime: *l.EndTime, EndTime: m.EndTime}
+		if err := r.save(&rest, "insert"); err != nil {
+			return err
+		}
+		r.trackers = append(r.trackers, rest)
+	}
+	r.sort()
+	return nil
+}
+
+// applyGapRules labels the gaps of the day with the first matching rule until
+// no rule applies, and returns the gaps left unresolved.
+func applyGapRules(r *reconciliation, rules []config.GapRule) ([]gap, error) {
+	for {
+		gaps := findGaps(r.trackers, r.start, r.end)
+		applied, err := applyFirstRule(gaps, rules)
+		if err != nil {
+			return nil, err
+		}
+		if len(applied) == 0 {
+			return gaps, nil
+		}
+
+		count := len(r.trackers)
+		for _, tracker := range applied {
+			if err := r.add(tracker); err != nil {
+				return nil, err
+			}
+		}
+		if len(r.trackers) == count {
+			// every segment was a duplicate, the gaps won't change
+			return gaps, nil
+		}
+	}
+}
+
+// applyFirstRule returns the segments produced by the first rule applying to
+// one of the gaps.
+func applyFirstRule(gaps []gap, rules []config.GapRule) ([]domain.DailyTracker, error) {
+	for _, g := range gaps {
+		for _, rule := range rules {
+			spans, err := ruleSpans(rule, g)
+			if err != nil {
+				return nil, err
+			}
+			if len(spans) == 0 {
+				continue
+			}
+
+			var trackers []domain.DailyTracker
+			for _, span := range spans {
+				end := span[1]
+				trackers = append(trackers, domain.DailyTracker{
+					Activity:  rule.Activity,
+					StartTime: span[0],
+					EndTime:   &end,
+				})
+			}
+			return trackers, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,206 @@
+package task
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/atony2099/pomo/domain"
+)
+
+// testDay is a past day, so that open segments do not run until now.
+var testDay = time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+// at returns the time "hh:mm" on testDay.
+func at(t *testing.T, clock string) time.Time {
+	t.Helper()
+	tm, err := parseClock(testDay, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+// segment returns a segment of testDay, open when end is empty.
+func segment(t *testing.T, activity, start, end, entryID string) domain.DailyTracker {
+	t.Helper()
+	tracker := domain.DailyTracker{Activity: activity, StartTime: at(t, start), TimeEntryID: entryID}
+	if end != "" {
+		endTime := at(t, end)
+		tracker.EndTime = &endTime
+	}
+	return tracker
+}
+
+// describe lists the segments as "activity hh:mm-hh:mm entry".
+func describe(trackers []domain.DailyTracker) []string {
+	var lines []string
+	for _, tracker := range trackers {
+		end := "open"
+		if tracker.EndTime != nil {
+			end = tracker.EndTime.Format("15:04")
+		}
+		lines = append(lines, tracker.Activity+" "+tracker.StartTime.Format("15:04")+"-"+end+" "+tracker.TimeEntryID)
+	}
+	return lines
+}
+
+func TestResolveOverlaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		trackers []domain.DailyTracker
+		want     []string
+		flagged  int
+	}{
+		{
+			name: "manual segments are merged",
+			trackers: []domain.DailyTracker{
+				segment(t, "read", "09:00", "10:00", ""),
+				segment(t, "read", "09:30", "11:00", ""),
+			},
+			want: []string{"read 09:00-11:00 "},
+		},
+		{
+			name: "manual segment ending inside a linked one is trimmed",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "09:00", "09:45", ""),
+				segment(t, "study", "09:30", "09:55", "e1"),
+			},
+			want: []string{"study 09:00-09:30 ", "study 09:30-09:55 e1"},
+		},
+		{
+			name: "manual segment starting inside a linked one is trimmed",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "09:00", "09:25", "e1"),
+				segment(t, "study", "09:10", "10:00", ""),
+			},
+			want: []string{"study 09:00-09:25 e1", "study 09:25-10:00 "},
+		},
+		{
+			name: "manual segment around a linked one is split",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "09:00", "11:00", ""),
+				segment(t, "study", "09:30", "09:55", "e1"),
+			},
+			want: []string{"study 09:00-09:30 ", "study 09:30-09:55 e1", "study 09:55-11:00 "},
+		},
+		{
+			name: "open manual segment after a linked one keeps running",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "09:00", "09:25", "e1"),
+				segment(t, "study", "09:00", "", ""),
+			},
+			want: []string{"study 09:00-09:25 e1", "study 09:25-open "},
+		},
+		{
+			name: "manual segment inside a linked one is dropped",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "09:00", "09:25", "e1"),
+				segment(t, "study", "09:05", "09:20", ""),
+			},
+			want: []string{"study 09:00-09:25 e1"},
+		},
+		{
+			name: "linked segments are flagged",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "09:00", "09:25", "e1"),
+				segment(t, "study", "09:20", "09:45", "e2"),
+			},
+			want:    []string{"study 09:00-09:25 e1", "study 09:20-09:45 e2"},
+			flagged: 1,
+		},
+		{
+			name: "different activities starting together are flagged",
+			trackers: []domain.DailyTracker{
+				segment(t, "read", "09:00", "10:00", ""),
+				segment(t, "walk", "09:00", "09:30", ""),
+			},
+			want:    []string{"read 09:00-10:00 ", "walk 09:00-09:30 "},
+			flagged: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &reconciliation{trackers: tt.trackers, dryRun: true}
+			r.sort()
+			flagged, err := r.resolveOverlaps()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(r.trackers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %q, want %q", got, tt.want)
+			}
+			if len(flagged) != tt.flagged {
+				t.Errorf("flagged %d overlaps, want %d", len(flagged), tt.flagged)
+			}
+
+			// a second reconciliation changes nothing
+			before := describe(r.trackers)
+			if _, err := r.resolveOverlaps(); err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(r.trackers); !reflect.DeepEqual(got, before) {
+				t.Errorf("second run changed segments to %q, want %q", got, before)
+			}
+		})
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	dayStart, dayEnd := at(t, "08:00"), at(t, "18:00")
+	tests := []struct {
+		name     string
+		trackers []domain.DailyTracker
+		want     []gap
+	}{
+		{
+			name: "untracked day",
+			want: []gap{{Start: dayStart, End: dayEnd}},
+		},
+		{
+			name: "gaps around and between segments",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "09:00", "10:00", ""),
+				segment(t, "read", "11:00", "17:00", ""),
+			},
+			want: []gap{
+				{Start: dayStart, End: at(t, "09:00"), Before: "study"},
+				{Start: at(t, "10:00"), End: at(t, "11:00"), After: "study", Before: "read"},
+				{Start: at(t, "17:00"), End: dayEnd, After: "read"},
+			},
+		},
+		{
+			name: "gaps of a minute are ignored",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "08:01", "12:00", ""),
+				segment(t, "read", "12:01", "17:59", ""),
+			},
+		},
+		{
+			name: "overlapping segments leave no gap",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "08:00", "12:00", ""),
+				segment(t, "read", "09:00", "10:00", ""),
+				segment(t, "walk", "11:30", "18:00", ""),
+			},
+		},
+		{
+			name: "open segment of a past day hides the rest",
+			trackers: []domain.DailyTracker{
+				segment(t, "study", "08:00", "09:00", ""),
+				segment(t, "read", "10:00", "", ""),
+			},
+			want: []gap{{Start: at(t, "09:00"), End: at(t, "10:00"), After: "study", Before: "read"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findGaps(tt.trackers, dayStart, dayEnd)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findGaps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
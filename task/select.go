@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
 )
 
 func SelectTask(offset int, isTotal bool) {
@@ -26,9 +27,10 @@ func SelectTask(offset int, isTotal bool) {
 
 	fmt.Printf("Selecting task from %d days ago\n", offset)
 
-	day := time.Now().AddDate(0, 0, -offset).Format("2006-01-02")
+	date := domain.Now().AddDate(0, 0, -offset)
+	dayStart := startOfDay(date)
 	// fmt.Printf("Start time: %s\n", startTime)
-	list, err := db.SelectTimeEntry(day)
+	list, err := db.SelectTimeEntry(dayStart, dayStart.AddDate(0, 0, 1))
 	if err != nil {
 
 		fmt.Printf("Error selecting task from day: %v\n", err)
@@ -40,19 +42,14 @@ func SelectTask(offset int, isTotal bool) {
 		duration := l.EndTime.Sub(l.StartTime)
 		fmt.Printf("%-10s: %s - %s, %v\n", l.TaskName, l.StartTime.Format("2006-01-02 15:04:05"), l.EndTime.Format("2006-01-02 15:04:05"), duration)
 	}
-	// get total duration for every day
+	// get total duration for every day, a session crossing the start of a
+	// day counts on both with its portion on each
 
 	var maps = make(map[string]time.Duration)
 
 	for _, l := range list {
-
-		// get the date of the start time
-		date := l.StartTime.Format("2006-01-02")
-
-		if _, ok := maps[date]; !ok {
-			maps[date] = l.EndTime.Sub(l.StartTime)
-		} else {
-			maps[date] += l.EndTime.Sub(l.StartTime)
+		for _, portion := range splitByDay(l.StartTime, l.EndTime) {
+			maps[dayKey(portion.Start)] += portion.End.Sub(portion.Start)
 		}
 	}
 
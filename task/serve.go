@@ -0,0 +1,215 @@
+package task
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+	"gorm.io/gorm"
+)
+
+// dashboardFiles is the single-page dashboard served at the root.
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// errNotFound answers requests for unknown records, errBadRequest wraps the
+// errors of invalid query parameters.
+var (
+	errNotFound   = errors.New("not found")
+	errBadRequest = errors.New("bad request")
+)
+
+// api serves the read-only JSON API over the database and the session.
+// Durations are in nanoseconds, as encoded by encoding/json.
+type api struct {
+	conf *config.Configuration
+}
+
+// ServeCommand runs `pomo serve [-addr host:port]`, which serves the JSON API
+// and the dashboard until interrupted.
+func ServeCommand(args []string, conf *config.Configuration) {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", conf.ServeAddr, "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+
+	handler, err := newServeMux(conf)
+	if err != nil {
+		fmt.Printf("Error loading dashboard: %v\n", err)
+		return
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("pomo dashboard on http://%s/\n", *addr)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Printf("Error serving: %v\n", err)
+	}
+}
+
+// newServeMux routes the API endpoints and the dashboard.
+func newServeMux(conf *config.Configuration) (*http.ServeMux, error) {
+	a := api{conf: conf}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tasks", getJSON(a.tasks))
+	mux.HandleFunc("/api/tasks/", getJSON(a.task))
+	mux.HandleFunc("/api/session", getJSON(a.session))
+	mux.HandleFunc("/api/entries", getJSON(a.entries))
+	mux.HandleFunc("/api/trackers", getJSON(a.trackers))
+	mux.HandleFunc("/api/reports/day", getJSON(a.dayReport))
+	mux.HandleFunc("/api/reports/week", getJSON(a.weekReport))
+
+	dashboard, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		return nil, err
+	}
+	mux.Handle("/", http.FileServer(http.FS(dashboard)))
+	return mux, nil
+}
+
+// get turns an endpoint returning a value into a handler of GET requests
+// answering with the value as JSON.
+func getJSON(endpoint func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		value, err := endpoint(r)
+		switch {
+		case errors.Is(err, errNotFound) || errors.Is(err, gorm.ErrRecordNotFound):
+			writeJSONError(w, http.StatusNotFound, err)
+		case errors.Is(err, errBadRequest):
+			writeJSONError(w, http.StatusBadRequest, err)
+		case err != nil:
+			writeJSONError(w, http.StatusInternalServerError, err)
+		default:
+			writeJSON(w, http.StatusOK, value)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// dayParam returns the start of the day named by the query parameter key,
+// today when it is not set.
+func dayParam(r *http.Request, key string) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return startOfDay(domain.Now()), nil
+	}
+	day, err := parseDay(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s is not a day as %s", errBadRequest, key, dayLayout)
+	}
+	return day, nil
+}
+
+// rangeParams returns the days from the from and to query parameters, both
+// included, today by default.
+func rangeParams(r *http.Request) (time.Time, time.Time, error) {
+	from, err := dayParam(r, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to := from
+	if r.URL.Query().Get("to") != "" {
+		if to, err = dayParam(r, "to"); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to is before from", errBadRequest)
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// tasks answers GET /api/tasks with the synced tasks.
+func (a api) tasks(r *http.Request) (interface{}, error) {
+	return db.GetTasks()
+}
+
+// task answers GET /api/tasks/<id> with a task and its time entries.
+func (a api) task(r *http.Request) (interface{}, error) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
+	if id == "" || strings.Contains(id, "/") {
+		return nil, errNotFound
+	}
+	task, err := db.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := db.SelectTaskEntries(id)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"Task": task, "Entries": entries}, nil
+}
+
+// session answers GET /api/session with the running session.
+func (a api) session(r *http.Request) (interface{}, error) {
+	return currentSession(a.conf.SocketPath())
+}
+
+// entries answers GET /api/entries?from=&to= with the time entries of the
+// days.
+func (a api) entries(r *http.Request) (interface{}, error) {
+	from, to, err := rangeParams(r)
+	if err != nil {
+		return nil, err
+	}
+	return db.SelectEntriesWithProject(from, to)
+}
+
+// trackers answers GET /api/trackers?from=&to= with the daily tracker
+// segments of the days.
+func (a api) trackers(r *http.Request) (interface{}, error) {
+	from, to, err := rangeParams(r)
+	if err != nil {
+		return nil, err
+	}
+	return db.SelectDailyTracker(from, to)
+}
+
+// dayReport answers GET /api/reports/day?day= with the review of the day.
+func (a api) dayReport(r *http.Request) (interface{}, error) {
+	day, err := dayParam(r, "day")
+	if err != nil {
+		return nil, err
+	}
+	return ComputeDayReview(day.Format(dayLayout), a.conf.Rules.Breaks)
+}
+
+// weekReport answers GET /api/reports/week?day= with the review of the week
+// the day falls in.
+func (a api) weekReport(r *http.Request) (interface{}, error) {
+	day, err := dayParam(r, "day")
+	if err != nil {
+		return nil, err
+	}
+	return ComputeWeekReview(startOfWeek(day), a.conf.Goals)
+}
@@ -10,18 +10,19 @@ import (
 	"github.com/atony2099/pomo/db"
 )
 
-// DisplayTasks prints the list of tasks and returns them.
-func DisplayTasks() ([]db.Task, error) {
+// DisplayTasks prints the list of tasks passing the filter and returns them.
+func DisplayTasks(filter TaskFilter) ([]db.Task, error) {
 	tasks, err := db.GetTasks()
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving tasks: %w", err)
 	}
+	tasks = filter.Apply(tasks)
 
 	var mainTasks []db.Task
 	for _, task := range tasks {
 		if task.ParentTaskID == "" {
 			mainTasks = append(mainTasks, task)
-			fmt.Printf("%d. %s (%s):\n", len(mainTasks), task.Name, task.ProjectName)
+			fmt.Printf("%d. %s (%s)%s:\n", len(mainTasks), task.Name, task.ProjectName, taskLabels(task))
 			displaySubtasks(task.TaskID, tasks)
 		}
 	}
@@ -34,14 +35,20 @@ func displaySubtasks(mainTaskID string, tasks []db.Task) {
 	for _, task := range tasks {
 		if task.ParentTaskID == mainTaskID {
 			subtaskCount++
-			fmt.Printf(" [%d]. %s\n", subtaskCount, task.Name)
+			fmt.Printf(" [%d]. %s%s\n", subtaskCount, task.Name, taskLabels(task))
 		}
 	}
 }
 
-// SetPomodoroConfig sets the configuration for Pomodoro based on user input.
-func SetPomodoroConfig() {
-	tasks, err := DisplayTasks()
+// SetPomodoroConfig sets the configuration for Pomodoro based on user input,
+// picking among the tasks passing the filter.
+func SetPomodoroConfig(filter TaskFilter) {
+	if err := filter.Validate(); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+
+	tasks, err := DisplayTasks(filter)
 	if err != nil {
 		fmt.Printf("Error displaying tasks: %v\n", err)
 		return
@@ -0,0 +1,174 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// Stats are the long term habits and records computed from time entries.
+type Stats struct {
+	Sessions      int
+	Focus         time.Duration
+	CurrentStreak int
+	LongestStreak int
+	BestDay       string
+	BestDayFocus  time.Duration
+	BestWeek      string
+	BestWeekFocus time.Duration
+	Completed     int
+	Interrupted   int
+	HourFocus     [24]time.Duration
+	Tasks         []TaskStats
+}
+
+// TaskStats are the session outcomes of a single task.
+type TaskStats struct {
+	Task        string
+	Focus       time.Duration
+	Completed   int
+	Interrupted int
+}
+
+// CompletionRate returns the share of the task's sessions that were completed.
+func (t TaskStats) CompletionRate() float64 {
+	if t.Completed+t.Interrupted == 0 {
+		return 0
+	}
+	return float64(t.Completed) / float64(t.Completed+t.Interrupted)
+}
+
+// AverageSession returns the mean length of a session.
+func (s Stats) AverageSession() time.Duration {
+	if s.Sessions == 0 {
+		return 0
+	}
+	return s.Focus / time.Duration(s.Sessions)
+}
+
+// CompletionRate returns the share of sessions that were completed.
+func (s Stats) CompletionRate() float64 {
+	if s.Completed+s.Interrupted == 0 {
+		return 0
+	}
+	return float64(s.Completed) / float64(s.Completed+s.Interrupted)
+}
+
+// MostFocusedHour returns the hour of day with the most focus time.
+func (s Stats) MostFocusedHour() int {
+	best := 0
+	for hour, focus := range s.HourFocus {
+		if focus > s.HourFocus[best] {
+			best = hour
+		}
+	}
+	return best
+}
+
+// ComputeStats calculates the statistics over every recorded time entry.
+// Entries without a recorded outcome count as interrupted when shorter than
+// pomodoroDuration.
+func ComputeStats(pomodoroDuration time.Duration, now time.Time) (Stats, error) {
+	entries, err := db.SelectEntriesWithProject(time.Unix(0, 0), startOfDay(now).AddDate(0, 0, 1))
+	if err != nil {
+		return Stats{}, fmt.Errorf("error selecting time entries: %w", err)
+	}
+	return computeStats(entries, pomodoroDuration, now), nil
+}
+
+func computeStats(entries []domain.ProjectEntry, pomodoroDuration time.Duration, now time.Time) Stats {
+	var stats Stats
+	weeks := make(map[string]time.Duration)
+	tasks := make(map[string]*TaskStats)
+	for _, entry := range entries {
+		duration := entry.EndTime.Sub(entry.StartTime)
+		stats.Sessions++
+		stats.Focus += duration
+
+		task, ok := tasks[entry.Title()]
+		if !ok {
+			task = &TaskStats{Task: entry.Title()}
+			tasks[entry.Title()] = task
+		}
+		task.Focus += duration
+		if entry.Completed(pomodoroDuration) {
+			stats.Completed++
+			task.Completed++
+		} else {
+			stats.Interrupted++
+			task.Interrupted++
+		}
+
+		// spread the session over the hours it covers
+		for start := entry.StartTime; start.Before(entry.EndTime); {
+			end := start.Truncate(time.Hour).Add(time.Hour)
+			if end.After(entry.EndTime) {
+				end = entry.EndTime
+			}
+			stats.HourFocus[start.Hour()] += end.Sub(start)
+			start = end
+		}
+	}
+
+	for _, task := range tasks {
+		stats.Tasks = append(stats.Tasks, *task)
+	}
+	sort.Slice(stats.Tasks, func(i, j int) bool {
+		return stats.Tasks[i].Focus > stats.Tasks[j].Focus
+	})
+
+	days := focusByDay(entries)
+	for day, focus := range days {
+		if focus.Focus > stats.BestDayFocus {
+			stats.BestDay, stats.BestDayFocus = day, focus.Focus
+		}
+		if start, err := parseDay(day); err == nil {
+			weeks[dayKey(startOfWeek(start))] += focus.Focus
+		}
+	}
+	for week, focus := range weeks {
+		if focus > stats.BestWeekFocus {
+			stats.BestWeek, stats.BestWeekFocus = week, focus
+		}
+	}
+
+	if len(entries) > 0 {
+		stats.CurrentStreak, stats.LongestStreak = streaks(entries[0].StartTime, now, func(day string) bool {
+			return days[day].Pomodoros > 0
+		})
+	}
+
+	return stats
+}
+
+// ShowStats prints streaks, personal records and session statistics.
+func ShowStats(pomodoroTime int) {
+	stats, err := ComputeStats(time.Duration(pomodoroTime)*time.Minute, domain.Now())
+	if err != nil {
+		fmt.Printf("Error computing stats: %v\n", err)
+		return
+	}
+
+	if stats.Sessions == 0 {
+		fmt.Println("No sessions recorded yet")
+		return
+	}
+
+	fmt.Printf("%-20s: %d days\n", "Current streak", stats.CurrentStreak)
+	fmt.Printf("%-20s: %d days\n", "Longest streak", stats.LongestStreak)
+	fmt.Printf("%-20s: %s (%v)\n", "Best day", stats.BestDay, stats.BestDayFocus.Round(time.Minute))
+	fmt.Printf("%-20s: week of %s (%v)\n", "Best week", stats.BestWeek, stats.BestWeekFocus.Round(time.Minute))
+	fmt.Printf("%-20s: %d (%v)\n", "Sessions", stats.Sessions, stats.Focus.Round(time.Minute))
+	fmt.Printf("%-20s: %v\n", "Average session", stats.AverageSession().Round(time.Second))
+	fmt.Printf("%-20s: %d / %d (%.0f%% completed)\n", "Completed/stopped", stats.Completed, stats.Interrupted, stats.CompletionRate()*100)
+	hour := stats.MostFocusedHour()
+	fmt.Printf("%-20s: %02d:00-%02d:00 (%v)\n", "Most focused hour", hour, hour+1, stats.HourFocus[hour].Round(time.Minute))
+
+	fmt.Println("\nCompletion rate per task:")
+	for _, task := range stats.Tasks {
+		fmt.Printf("%-20s: %3.0f%% of %d sessions, %v\n", task.Task, task.CompletionRate()*100, task.Completed+task.Interrupted, task.Focus.Round(time.Minute))
+	}
+}
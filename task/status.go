@@ -0,0 +1,79 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/cache"
+	"github.com/atony2099/pomo/domain"
+)
+
+// DefaultStatusFormat is the status line used when none is configured.
+const DefaultStatusFormat = "{phase} {remaining} {task}"
+
+// formatStatus fills the placeholders of format with the session at now:
+// {phase}, {remaining}, {elapsed}, {task}, {subtask} and {project}. An idle
+// session has no times.
+func formatStatus(format string, session cache.Session, now time.Time) string {
+	remaining, elapsed := "--:--", "--:--"
+	if session.Phase != cache.PhaseIdle {
+		remaining = clock(session.Remaining(now))
+		elapsed = clock(now.Sub(session.Start))
+	}
+
+	replacer := strings.NewReplacer(
+		"{phase}", strings.ReplaceAll(session.Phase, "_", " "),
+		"{remaining}", remaining,
+		"{elapsed}", elapsed,
+		"{task}", session.Task,
+		"{subtask}", session.Subtask,
+		"{project}", session.Project,
+	)
+	return strings.TrimSpace(replacer.Replace(format))
+}
+
+// clock formats d as mm:ss.
+func clock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// ShowStatus prints the running session on one line, for status bars and
+// prompts. The daemon on socket is asked first, the cache when none runs.
+// Errors go to stderr and the line shows idle, so that status bars never
+// display them.
+func ShowStatus(format, socket string) {
+	if format == "" {
+		format = DefaultStatusFormat
+	}
+	session, err := currentSession(socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting session: %v\n", err)
+		session = cache.Session{Phase: cache.PhaseIdle}
+	}
+	fmt.Println(formatStatus(format, session, domain.Now()))
+}
+
+// currentSession returns the session of the daemon on socket, or the one in
+// the cache when no daemon runs.
+func currentSession(socket string) (cache.Session, error) {
+	if resp, err := daemonCall(socket, daemonRequest{Command: CommandStatus}); err == nil {
+		return resp.Session, nil
+	}
+	return cache.GetSession()
+}
+
+// publishSession shares the phase of the running session with `pomo status`.
+// Errors are ignored as the screen belongs to termbox and the timer goes on
+// without it.
+func publishSession(phase string, start, end time.Time) {
+	session := cache.Session{Phase: phase, Start: start, End: end}
+	if task, err := cache.GetSelectedTask(); err == nil {
+		session.Task, session.Subtask, session.Project = task.Name, task.SubName, task.Project
+	}
+	_ = cache.SetSession(session)
+}
@@ -0,0 +1,394 @@
+package task
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// Headers of webhook requests. The signature is "sha256=" followed by the
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the webhook secret, the
+// delivery id lets receivers drop the deliveries they already got.
+const (
+	webhookEventHeader     = "X-Pomo-Event"
+	webhookDeliveryHeader  = "X-Pomo-Delivery"
+	webhookTimestampHeader = "X-Pomo-Timestamp"
+	webhookSignatureHeader = "X-Pomo-Signature"
+)
+
+// webhookTimeout bounds a delivery.
+const webhookTimeout = 10 * time.Second
+
+// webhookLease is how long a claimed delivery is left to the process posting
+// it before the others retry it.
+const webhookLease = 2 * webhookTimeout
+
+// maxWebhookBackoff caps the wait between two attempts of a delivery.
+const maxWebhookBackoff = 6 * time.Hour
+
+// webhooks are the configured webhooks.
+var webhooks []config.Webhook
+
+// SetWebhooks sets the webhooks saved sessions and closed days are posted to.
+func SetWebhooks(configured []config.Webhook) {
+	webhooks = configured
+}
+
+// deliveryMu keeps a process from posting the same delivery twice at once,
+// the claims of the deliveries keep the other processes from posting it.
+var deliveryMu sync.Mutex
+
+// webhookPayload is the body posted to webhooks.
+type webhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookEntry is the data of a time_entry payload.
+type webhookEntry struct {
+	ID             string    `json:"id"`
+	TaskID         string    `json:"task_id"`
+	TaskName       string    `json:"task_name"`
+	Project        string    `json:"project"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Outcome        string    `json:"outcome"`
+	PlannedSeconds int64     `json:"planned_seconds"`
+	ActualSeconds  int64     `json:"actual_seconds"`
+}
+
+// webhookDay is the data of a day_closed payload, sent by `pomo -complete`.
+// Completing a day again closes it again, receivers should replace the
+// earlier payloads.
+type webhookDay struct {
+	Day                 string            `json:"day"`
+	Pomodoros           int               `json:"pomodoros"`
+	FocusSeconds        int64             `json:"focus_seconds"`
+	BreakSeconds        int64             `json:"break_seconds"`
+	DistractionSeconds  int64             `json:"distraction_seconds"`
+	UntrackedSeconds    int64             `json:"untracked_seconds"`
+	Tasks               []webhookTaskTime `json:"tasks"`
+	AveragePomodoros    float64           `json:"average_pomodoros"`
+	AverageFocusSeconds int64             `json:"average_focus_seconds"`
+}
+
+type webhookTaskTime struct {
+	Task         string `json:"task"`
+	FocusSeconds int64  `json:"focus_seconds"`
+}
+
+// queueWebhook queues the payload of event for the webhooks receiving it,
+// and posts the due deliveries in the background. Deliveries that do not
+// make it before the process exits are posted by the next ones, by the
+// daemon or by `pomo webhooks flush`.
+func queueWebhook(event string, data interface{}) error {
+	payload, err := json.Marshal(webhookPayload{Event: event, CreatedAt: domain.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []domain.WebhookDelivery
+	for _, webhook := range webhooks {
+		if webhook.Wants(event) {
+			deliveries = append(deliveries, domain.WebhookDelivery{
+				URL:         webhook.URL,
+				Event:       event,
+				Payload:     string(payload),
+				NextAttempt: time.Now(),
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := db.QueueWebhookDeliveries(deliveries); err != nil {
+		return fmt.Errorf("error queueing webhook: %v", err)
+	}
+
+	go deliverWebhooks(nil)
+	return nil
+}
+
+// queueTimeEntry queues the time_entry payload of a saved session.
+func queueTimeEntry(entry domain.TimeEntry, project string) error {
+	return queueWebhook(config.WebhookTimeEntry, webhookEntry{
+		ID:             entry.ID,
+		TaskID:         entry.TaskID,
+		TaskName:       entry.TaskName,
+		Project:        project,
+		Start:          entry.StartTime,
+		End:            entry.EndTime,
+		Outcome:        entry.Outcome,
+		PlannedSeconds: entry.PlannedDuration,
+		ActualSeconds:  entry.ActualDuration,
+	})
+}
+
+// queueDayClosed queues the day_closed payload of a reconciled day.
+func queueDayClosed(day string, rules []config.BreakRule) error {
+	if !wantsWebhook(config.WebhookDayClosed) {
+		return nil
+	}
+	review, err := ComputeDayReview(day, rules)
+	if err != nil {
+		return err
+	}
+
+	data := webhookDay{
+		Day:                 review.Day,
+		Pomodoros:           review.Pomodoros,
+		FocusSeconds:        int64(review.Focus.Seconds()),
+		BreakSeconds:        int64(review.Breaks.Seconds()),
+		DistractionSeconds:  int64(review.Distractions.Seconds()),
+		UntrackedSeconds:    int64(review.Untracked.Seconds()),
+		Tasks:               []webhookTaskTime{},
+		AveragePomodoros:    review.AveragePomodoros,
+		AverageFocusSeconds: int64(review.AverageFocus.Seconds()),
+	}
+	for _, task := range review.Tasks {
+		data.Tasks = append(data.Tasks, webhookTaskTime{Task: task.Task, FocusSeconds: int64(task.Focus.Seconds())})
+	}
+	return queueWebhook(config.WebhookDayClosed, data)
+}
+
+// wantsWebhook reports whether a webhook receives event.
+func wantsWebhook(event string) bool {
+	for _, webhook := range webhooks {
+		if webhook.Wants(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// signWebhook returns the signature of a body posted at timestamp.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the wait before the next attempt after attempts
+// failed ones: a minute, doubling up to maxWebhookBackoff.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := time.Minute
+	for i := 1; i < attempts && backoff < maxWebhookBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxWebhookBackoff {
+		backoff = maxWebhookBackoff
+	}
+	return backoff
+}
+
+// deliverWebhooks posts the due deliveries, and returns how many were
+// delivered and how many failed. Failures are reported to out unless it is
+// nil, as in the background the terminal may belong to termbox.
+func deliverWebhooks(out io.Writer) (delivered, failed int) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+
+	report := func(format string, args ...interface{}) {
+		if out != nil {
+			fmt.Fprintf(out, format, args...)
+		}
+	}
+
+	now := time.Now()
+	deliveries, err := db.SelectWebhookDeliveries(now)
+	if err != nil {
+		report("Error selecting webhook deliveries: %v\n", err)
+		return 0, 0
+	}
+
+	secrets := make(map[string]string)
+	for _, webhook := range webhooks {
+		secrets[webhook.URL] = webhook.Secret
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	for _, delivery := range deliveries {
+		secret, ok := secrets[delivery.URL]
+		if !ok {
+			report("Dropping delivery %d to %s, which is no longer configured\n", delivery.ID, delivery.URL)
+			if err := db.DeleteWebhookDelivery(delivery.ID); err != nil {
+				report("Error deleting delivery %d: %v\n", delivery.ID, err)
+			}
+			continue
+		}
+
+		claimed, err := db.ClaimWebhookDelivery(delivery.ID, now, time.Now().Add(webhookLease))
+		if err != nil {
+			report("Error claiming delivery %d: %v\n", delivery.ID, err)
+			continue
+		}
+		if !claimed {
+			// another process is posting it
+			continue
+		}
+
+		if err := postWebhook(client, delivery, secret); err != nil {
+			failed++
+			delivery.Attempts++
+			delivery.LastError = err.Error()
+			delivery.NextAttempt = time.Now().Add(webhookBackoff(delivery.Attempts))
+			report("Error delivering %s %d to %s: %v\n", delivery.Event, delivery.ID, delivery.URL, err)
+			if err := db.SaveWebhookDelivery(delivery); err != nil {
+				report("Error saving delivery %d: %v\n", delivery.ID, err)
+			}
+			continue
+		}
+
+		delivered++
+		if err := db.DeleteWebhookDelivery(delivery.ID); err != nil {
+			report("Error deleting delivery %d: %v\n", delivery.ID, err)
+		}
+	}
+	return delivered, failed
+}
+
+// postWebhook posts a delivery signed with secret. Any status but 2xx is a
+// failure.
+func postWebhook(client *http.Client, delivery domain.WebhookDelivery, secret string) error {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pomo-webhook")
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookSignatureHeader, signWebhook(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("response error: %s - %s", resp.Status, body)
+	}
+	return nil
+}
+
+const webhooksUsage = `usage: pomo webhooks <command> [arguments]
+
+  list                            queued deliveries
+  flush                           post the due deliveries now
+  listen [-addr a] [-secret s]    print the webhooks received on addr`
+
+// WebhooksCommand runs a `pomo webhooks` subcommand.
+func WebhooksCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println(webhooksUsage)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		listWebhookDeliveries()
+	case "flush":
+		delivered, failed := deliverWebhooks(os.Stdout)
+		fmt.Printf("delivered %d, failed %d\n", delivered, failed)
+	case "listen":
+		listenWebhooks(args[1:])
+	default:
+		fmt.Printf("unknown command %q\n%s\n", args[0], webhooksUsage)
+	}
+}
+
+func listWebhookDeliveries() {
+	deliveries, err := db.SelectWebhookDeliveries(time.Time{})
+	if err != nil {
+		fmt.Printf("Error selecting webhook deliveries: %v\n", err)
+		return
+	}
+	if len(deliveries) == 0 {
+		fmt.Println("no queued deliveries")
+		return
+	}
+	for _, d := range deliveries {
+		fmt.Printf("%d %s %s queued %s, %d attempts, next %s",
+			d.ID, d.Event, d.URL, d.CreatedAt.Format("2006-01-02 15:04"), d.Attempts, d.NextAttempt.Format("2006-01-02 15:04"))
+		if d.LastError != "" {
+			fmt.Printf(": %s", d.LastError)
+		}
+		fmt.Println()
+	}
+}
+
+// webhookTolerance is how old a received timestamp may be.
+const webhookTolerance = 5 * time.Minute
+
+// listenWebhooks receives webhooks on a local address and prints them with
+// whether their signature holds, to try the configuration out. The secret is
+// that of the first webhook by default.
+func listenWebhooks(args []string) {
+	flags := flag.NewFlagSet("listen", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8026", "address to listen on")
+	secret := flags.String("secret", "", "secret to check the signatures with")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+	if *secret == "" && len(webhooks) > 0 {
+		*secret = webhooks[0].Secret
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		valid := verifyWebhook(*secret, r.Header, body, time.Now())
+		fmt.Printf("%s %s delivery %s, signature ok: %t\n%s\n",
+			domain.Now().Format("15:04:05"), r.Header.Get(webhookEventHeader), r.Header.Get(webhookDeliveryHeader), valid, body)
+		if !valid {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+		}
+	})
+
+	fmt.Printf("listening for webhooks on http://%s/\n", *addr)
+	server := &http.Server{Addr: *addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Printf("Error listening: %v\n", err)
+	}
+}
+
+// verifyWebhook reports whether the headers of a webhook request sign body
+// with secret at a time close enough to now.
+func verifyWebhook(secret string, header http.Header, body []byte, now time.Time) bool {
+	timestamp, err := strconv.ParseInt(header.Get(webhookTimestampHeader), 10, 64)
+	if err != nil {
+		return false
+	}
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > webhookTolerance || age < -webhookTolerance {
+		return false
+	}
+	expected := signWebhook(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(header.Get(webhookSignatureHeader)))
+}
@@ -0,0 +1,124 @@
+package task
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/atony2099/pomo/domain"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"time_entry"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := signWebhook("secret", 1700000000, body); got != want {
+		t.Errorf("signWebhook = %q, want %q", got, want)
+	}
+	if got := signWebhook("other", 1700000000, body); got == want {
+		t.Error("signature does not depend on the secret")
+	}
+	if got := signWebhook("secret", 1700000001, body); got == want {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"event":"day_closed"}`)
+	now := time.Unix(1700000000, 0)
+	header := func(secret string, at time.Time) http.Header {
+		h := http.Header{}
+		h.Set(webhookTimestampHeader, strconv.FormatInt(at.Unix(), 10))
+		h.Set(webhookSignatureHeader, signWebhook(secret, at.Unix(), body))
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   bool
+	}{
+		{name: "signed now", header: header("secret", now), body: body, want: true},
+		{name: "signed within the tolerance", header: header("secret", now.Add(-webhookTolerance)), body: body, want: true},
+		{name: "timestamp ahead within the tolerance", header: header("secret", now.Add(webhookTolerance)), body: body, want: true},
+		{name: "too old", header: header("secret", now.Add(-webhookTolerance-time.Second)), body: body},
+		{name: "too far ahead", header: header("secret", now.Add(webhookTolerance+time.Second)), body: body},
+		{name: "other secret", header: header("other", now), body: body},
+		{name: "changed body", header: header("secret", now), body: []byte(`{"event":"time_entry"}`)},
+		{name: "no headers", header: http.Header{}, body: body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyWebhook("secret", tt.header, tt.body, now); got != tt.want {
+				t.Errorf("verifyWebhook = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 4 * time.Minute},
+		{attempts: 9, want: 256 * time.Minute},
+		{attempts: 10, want: maxWebhookBackoff},
+		{attempts: 100, want: maxWebhookBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "not modified", status: http.StatusNotModified, wantErr: true},
+		{name: "rejected", status: http.StatusUnauthorized, wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := domain.WebhookDelivery{ID: 7, Event: "time_entry", Payload: `{"event":"time_entry"}`}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Header.Get(webhookEventHeader) != delivery.Event || r.Header.Get(webhookDeliveryHeader) != "7" {
+					t.Errorf("headers = %v", r.Header)
+				}
+				if !verifyWebhook("secret", r.Header, body, time.Now()) {
+					t.Error("signature does not verify")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			delivery.URL = server.URL
+			err := postWebhook(server.Client(), delivery, "secret")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("postWebhook error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
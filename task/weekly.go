@@ -0,0 +1,348 @@
+package task
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/atony2099/pomo/config"
+	"github.com/atony2099/pomo/db"
+	"github.com/atony2099/pomo/domain"
+)
+
+// WeekDay is the focus of one day of a week review.
+type WeekDay struct {
+	Day       time.Time
+	Pomodoros int
+	Focus     time.Duration
+	// GoalMet is whether the daily goal was reached.
+	GoalMet bool
+}
+
+// ProjectTotal is the focus spent on a project.
+type ProjectTotal struct {
+	Project   string
+	Pomodoros int
+	Focus     time.Duration
+}
+
+// CategoryTotal is the time tracked in an activity category.
+type CategoryTotal struct {
+	Category string
+	Duration time.Duration
+}
+
+// SessionNote is a note attached to a session.
+type SessionNote struct {
+	Start time.Time
+	Task  string
+	Note  string
+}
+
+// WeekReview summarizes a week from its time entries and segments.
+type WeekReview struct {
+	Start time.Time
+	// Days holds the days of the week up to today.
+	Days       []WeekDay
+	Projects   []ProjectTotal
+	Categories []CategoryTotal
+	// DailyGoal is the daily target the days are checked against, and
+	// Goals the progress toward the weekly targets.
+	DailyGoal config.Target
+	Goals     []GoalProgress
+	Notes     []SessionNote
+}
+
+// Focus returns the focus of the whole week.
+func (w WeekReview) Focus() time.Duration {
+	var focus time.Duration
+	for _, day := range w.Days {
+		focus += day.Focus
+	}
+	return focus
+}
+
+// Pomodoros returns the pomodoros of the whole week.
+func (w WeekReview) Pomodoros() int {
+	var pomodoros int
+	for _, day := range w.Days {
+		pomodoros += day.Pomodoros
+	}
+	return pomodoros
+}
+
+// DailyGoalsMet returns on how many days the daily goal was reached.
+func (w WeekReview) DailyGoalsMet() int {
+	var met int
+	for _, day := range w.Days {
+		if day.GoalMet {
+			met++
+		}
+	}
+	return met
+}
+
+// BestDay and WorstDay return the days with the most and the least focus.
+func (w WeekReview) BestDay() WeekDay {
+	var best WeekDay
+	for i, day := range w.Days {
+		if i == 0 || day.Focus > best.Focus {
+			best = day
+		}
+	}
+	return best
+}
+
+func (w WeekReview) WorstDay() WeekDay {
+	var worst WeekDay
+	for i, day := range w.Days {
+		if i == 0 || day.Focus < worst.Focus {
+			worst = day
+		}
+	}
+	return worst
+}
+
+// DayRatio returns the focus of day relative to the best day, for bars.
+func (w WeekReview) DayRatio(day WeekDay) float64 {
+	best := w.BestDay().Focus
+	if best == 0 {
+		return 0
+	}
+	return float64(day.Focus) / float64(best)
+}
+
+// ComputeWeekReview calculates the review of the week starting at start.
+func ComputeWeekReview(start time.Time, goals config.Goals) (WeekReview, error) {
+	end := start.AddDate(0, 0, 7)
+	entries, err := db.SelectEntriesWithProject(start, end)
+	if err != nil {
+		return WeekReview{}, fmt.Errorf("error selecting time entries: %w", err)
+	}
+	trackers, err := db.SelectDailyTracker(start, end)
+	if err != nil {
+		return WeekReview{}, fmt.Errorf("error selecting daily tracker: %v", err)
+	}
+	c, err := loadCatalogue()
+	if err != nil {
+		return WeekReview{}, err
+	}
+
+	review := WeekReview{Start: start, DailyGoal: goals.Daily}
+	days := focusByDay(entries)
+	now := domain.Now()
+	for day := start; day.Before(end) && !day.After(now); day = day.AddDate(0, 0, 1) {
+		focus := days[dayKey(day)]
+		review.Days = append(review.Days, WeekDay{
+			Day:       day,
+			Pomodoros: focus.Pomodoros,
+			Focus:     focus.Focus,
+			GoalMet:   targetMet(goals.Daily, focus),
+		})
+	}
+
+	projects := make(map[string]*ProjectTotal)
+	for _, entry := range entries {
+		name := entry.ProjectName
+		if name == "" {
+			name = "(no project)"
+		}
+		total, ok := projects[name]
+		if !ok {
+			total = &ProjectTotal{Project: name}
+			projects[name] = total
+		}
+		if !entry.StartTime.Before(start) {
+			total.Pomodoros++
+		}
+		total.Focus += clip(entry.StartTime, entry.EndTime, start, end)
+
+		if entry.Note != "" {
+			review.Notes = append(review.Notes, SessionNote{Start: entry.StartTime, Task: entry.Title(), Note: entry.Note})
+		}
+	}
+	for _, total := range projects {
+		review.Projects = append(review.Projects, *total)
+	}
+	sort.Slice(review.Projects, func(i, j int) bool {
+		return review.Projects[i].Focus > review.Projects[j].Focus
+	})
+
+	categories := make(map[string]time.Duration)
+	for _, tracker := range trackers {
+		trackerEnd := now
+		if tracker.EndTime != nil {
+			trackerEnd = *tracker.EndTime
+		}
+		categories[c.category(tracker.Activity)] += clip(tracker.StartTime, trackerEnd, start, end)
+	}
+	for category, duration := range categories {
+		review.Categories = append(review.Categories, CategoryTotal{Category: category, Duration: duration})
+	}
+	sort.Slice(review.Categories, func(i, j int) bool {
+		return review.Categories[i].Duration > review.Categories[j].Duration
+	})
+
+	weekly := GoalProgress{Name: "week", Target: goals.Weekly, Pomodoros: review.Pomodoros(), Focus: review.Focus()}
+	if weekly.Target.IsSet() {
+		review.Goals = append(review.Goals, weekly)
+	}
+	names := make([]string, 0, len(goals.Projects))
+	for name := range goals.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target := goals.Projects[name].Weekly
+		if !target.IsSet() {
+			continue
+		}
+		progress := GoalProgress{Name: name + " week", Target: target}
+		for _, total := range review.Projects {
+			// viper lower-cases map keys, so projects are matched case-insensitively
+			if strings.EqualFold(total.Project, name) {
+				progress.Pomodoros += total.Pomodoros
+				progress.Focus += total.Focus
+			}
+		}
+		review.Goals = append(review.Goals, progress)
+	}
+
+	return review, nil
+}
+
+// checkMark returns ✓ when ok, a space otherwise.
+func checkMark(ok bool) string {
+	if ok {
+		return "✓"
+	}
+	return " "
+}
+
+// WriteMarkdown renders the review as Markdown.
+func (w WeekReview) WriteMarkdown(out io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Week of %s\n\n", w.Start.Format(dayLayout))
+	fmt.Fprintf(&b, "Focus: %v in %d pomodoros\n", w.Focus().Round(time.Minute), w.Pomodoros())
+
+	b.WriteString("\n## Focus per day\n\n```\n")
+	for _, day := range w.Days {
+		fmt.Fprintf(&b, "%s %s %s %8v %3d pomodoros\n", day.Day.Format("Mon 2006-01-02"), textBar(w.DayRatio(day), 20),
+			checkMark(day.GoalMet && w.DailyGoal.IsSet()), day.Focus.Round(time.Minute), day.Pomodoros)
+	}
+	b.WriteString("```\n")
+
+	if len(w.Days) > 0 {
+		best, worst := w.BestDay(), w.WorstDay()
+		fmt.Fprintf(&b, "\n- Best day: %s (%v)\n", best.Day.Format("Mon 2006-01-02"), best.Focus.Round(time.Minute))
+		fmt.Fprintf(&b, "- Worst day: %s (%v)\n", worst.Day.Format("Mon 2006-01-02"), worst.Focus.Round(time.Minute))
+	}
+
+	if len(w.Projects) > 0 {
+		b.WriteString("\n## Projects\n\n| Project | Focus | Pomodoros |\n| --- | --- | --- |\n")
+		for _, project := range w.Projects {
+			fmt.Fprintf(&b, "| %s | %v | %d |\n", project.Project, project.Focus.Round(time.Minute), project.Pomodoros)
+		}
+	}
+
+	if len(w.Categories) > 0 {
+		b.WriteString("\n## Tracked time\n\n")
+		for _, category := range w.Categories {
+			fmt.Fprintf(&b, "- %s: %v\n", category.Category, category.Duration.Round(time.Minute))
+		}
+	}
+
+	if w.DailyGoal.IsSet() || len(w.Goals) > 0 {
+		b.WriteString("\n## Goals\n\n")
+		if w.DailyGoal.IsSet() {
+			fmt.Fprintf(&b, "- Daily goal met on %d of %d days\n", w.DailyGoalsMet(), len(w.Days))
+		}
+		for _, goal := range w.Goals {
+			done := " "
+			if goal.Met() {
+				done = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s: %s\n", done, goal.Name, goal)
+		}
+	}
+
+	if len(w.Notes) > 0 {
+		b.WriteString("\n## Notes\n\n")
+		for _, note := range w.Notes {
+			fmt.Fprintf(&b, "- %s %s: %s\n", note.Start.Format("Mon 15:04"), note.Task, note.Note)
+		}
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+var weekTemplate = template.Must(template.New("week").Funcs(template.FuncMap{
+	"date":    func(t time.Time, layout string) string { return t.Format(layout) },
+	"minutes": func(d time.Duration) string { return d.Round(time.Minute).String() },
+	"percent": func(ratio float64) string { return fmt.Sprintf("%.0f%%", ratio*100) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Week of {{date .Start "2006-01-02"}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+td, th { padding: 0.25em 0.5em; text-align: left; border-bottom: 1px solid #ddd; }
+.bar { background: #eee; width: 20em; }
+.bar div { background: #c0392b; height: 1em; }
+.met { color: #27ae60; }
+</style>
+</head>
+<body>
+<h1>Week of {{date .Start "2006-01-02"}}</h1>
+<p>Focus: {{minutes .Focus}} in {{.Pomodoros}} pomodoros</p>
+
+<h2>Focus per day</h2>
+<table>
+{{range .Days}}<tr>
+<td>{{date .Day "Mon 2006-01-02"}}</td>
+<td class="bar"><div style="width: {{percent ($.DayRatio .)}}"></div></td>
+<td>{{minutes .Focus}}</td>
+<td>{{.Pomodoros}} pomodoros</td>
+<td class="met">{{if and .GoalMet $.DailyGoal.IsSet}}✓{{end}}</td>
+</tr>
+{{end}}</table>
+{{if .Days}}<ul>
+<li>Best day: {{date .BestDay.Day "Mon 2006-01-02"}} ({{minutes .BestDay.Focus}})</li>
+<li>Worst day: {{date .WorstDay.Day "Mon 2006-01-02"}} ({{minutes .WorstDay.Focus}})</li>
+</ul>{{end}}
+
+{{if .Projects}}<h2>Projects</h2>
+<table>
+<tr><th>Project</th><th>Focus</th><th>Pomodoros</th></tr>
+{{range .Projects}}<tr><td>{{.Project}}</td><td>{{minutes .Focus}}</td><td>{{.Pomodoros}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .Categories}}<h2>Tracked time</h2>
+<ul>
+{{range .Categories}}<li>{{.Category}}: {{minutes .Duration}}</li>
+{{end}}</ul>{{end}}
+
+{{if or .DailyGoal.IsSet .Goals}}<h2>Goals</h2>
+<ul>
+{{if .DailyGoal.IsSet}}<li>Daily goal met on {{.DailyGoalsMet}} of {{len .Days}} days</li>{{end}}
+{{range .Goals}}<li{{if .Met}} class="met"{{end}}>{{.Name}}: {{.String}}</li>
+{{end}}</ul>{{end}}
+
+{{if .Notes}}<h2>Notes</h2>
+<ul>
+{{range .Notes}}<li>{{date .Start "Mon 15:04"}} {{.Task}}: {{.Note}}</li>
+{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders the review as a self-contained HTML page.
+func (w WeekReview) WriteHTML(out io.Writer) error {
+	return weekTemplate.Execute(out, w)
+}
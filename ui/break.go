@@ -0,0 +1,46 @@
+package ui
+
+import "time"
+
+// Break is what the break screen shows.
+type Break struct {
+	Total   time.Duration
+	Elapsed time.Duration
+	Long    bool
+	// Guide is the suggestion or routine step to follow now.
+	Guide string
+	// Task and Subtask are what the next pomodoro is for.
+	Task    string
+	Subtask string
+	// Attached is set when the break runs in the daemon.
+	Attached bool
+}
+
+const breakKeys = "s skip · e +5 min · n next pomodoro"
+
+// DrawBreak draws the remaining break time in the break color.
+func DrawBreak(b Break) {
+	next := ""
+	if b.Task != "" {
+		next = "next: " + taskTitle(b.Task, b.Subtask)
+	}
+	title := "break"
+	if b.Long {
+		title = "long break"
+	}
+	keys := breakKeys
+	if b.Attached {
+		keys += " · q detach"
+	}
+	drawTimer(timerScreen{
+		total:    b.Total,
+		elapsed:  b.Elapsed,
+		title:    title,
+		subtitle: b.Guide,
+		minutes:  theme.Break,
+		seconds:  theme.Break,
+		bar:      theme.Break,
+		info:     next,
+		keys:     keys,
+	})
+}
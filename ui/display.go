@@ -4,14 +4,13 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/nsf/termbox-go"
 )
 
-const progressBarWidth = 50
-
-func drawProgressBar(currentTime, totalTime int, width, y int) {
+func drawProgressBar(currentTime, totalTime int, width, y int, color termbox.Attribute) {
 	percentage := float64(currentTime) / float64(totalTime)
 	barWidth := (width / 2) - 2 // 50% of the screen width minus brackets
 	filledWidth := int(percentage * float64(barWidth))
@@ -19,152 +18,252 @@ func drawProgressBar(currentTime, totalTime int, width, y int) {
 
 	// Draw the filled part of the progress bar
 	for i := 0; i < filledWidth; i++ {
-		termbox.SetCell(startX+i, y, '█', termbox.ColorGreen, termbox.ColorGreen)
+		termbox.SetCell(startX+i, y, '█', color, termbox.ColorDefault)
 	}
 
 	// Draw the empty part of the progress bar
 	for i := filledWidth; i < barWidth; i++ {
-		termbox.SetCell(startX+i, y, ' ', termbox.ColorLightGray, termbox.ColorLightGray)
+		termbox.SetCell(startX+i, y, '░', theme.BarEmpty, termbox.ColorDefault)
 	}
 
 	// Draw the progress percentage
 	progressString := fmt.Sprintf(" %3.0f%%", percentage*100)
 	for i, r := range progressString {
-		termbox.SetCell(startX+barWidth+1+i, y, r, termbox.ColorWhite, termbox.ColorDefault)
+		termbox.SetCell(startX+barWidth+1+i, y, r, theme.Text, termbox.ColorDefault)
 	}
 }
 
-func DrawCountdownFull(total, elapsed time.Duration) {
+// Countdown is what the focus screen shows.
+type Countdown struct {
+	Total   time.Duration
+	Elapsed time.Duration
+	Task    string
+	Subtask string
+	Project string
+	// Session is the number of the pomodoro within a cycle of Cycle
+	// pomodoros.
+	Session int
+	Cycle   int
+	// TodayPomodoros and TodayFocus are the pomodoros already done today.
+	TodayPomodoros int
+	TodayFocus     time.Duration
+	// Status holds extra lines such as goal progress, empty ones are skipped.
+	Status []string
+	// Attached is set when the session runs in the daemon, which can pause
+	// it, and Paused while it is paused.
+	Attached bool
+	Paused   bool
+}
 
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+// title returns the task and subtask being worked on.
+func (c Countdown) title() string {
+	if c.Task == "" {
+		return "no task selected"
+	}
+	return taskTitle(c.Task, c.Subtask)
+}
+
+// taskTitle joins a task and its subtask, if any.
+func taskTitle(task, subtask string) string {
+	if subtask == "" {
+		return task
+	}
+	return task + " › " + subtask
+}
+
+// session describes the session within the cycle and the day.
+func (c Countdown) session() string {
+	text := fmt.Sprintf("today %d pomodoros, %v", c.TodayPomodoros, c.TodayFocus.Round(time.Minute))
+	if c.Cycle > 0 {
+		text = fmt.Sprintf("session %d/%d · %s", c.Session, c.Cycle, text)
+	}
+	if c.Paused {
+		text = "paused · " + text
+	}
+	return text
+}
+
+const (
+	countdownKeys = "space/esc stop"
+	attachedKeys  = "p pause · space/esc stop · q detach"
+	pausedKeys    = "p resume · space/esc stop · q detach"
+)
+
+// keys returns the key hint of the countdown.
+func (c Countdown) keys() string {
+	switch {
+	case c.Paused:
+		return pausedKeys
+	case c.Attached:
+		return attachedKeys
+	}
+	return countdownKeys
+}
+
+// remaining formats the time left of total as mm:ss.
+func remaining(total, elapsed time.Duration) string {
 	remain := total - elapsed
-	minutes := int(remain.Minutes())
-	seconds := int(remain.Seconds()) % 60
-	currentseconds := int(elapsed.Seconds())
-	totalSecond := int(total.Seconds())
+	if remain < 0 {
+		remain = 0
+	}
+	return fmt.Sprintf("%02d:%02d", int(remain.Minutes()), int(remain.Seconds())%60)
+}
+
+// timerScreen is the content of a full-screen timer.
+type timerScreen struct {
+	total    time.Duration
+	elapsed  time.Duration
+	title    string
+	subtitle string
+	// minutes and seconds are the colors of the digits, bar the color of
+	// the progress bar.
+	minutes termbox.Attribute
+	seconds termbox.Attribute
+	bar     termbox.Attribute
+	info    string
+	status  []string
+	keys    string
+}
 
-	countdownString := fmt.Sprintf("%02d:%02d", minutes, seconds)
+// DrawCountdown draws the remaining time with a progress bar, the task above
+// and the session and status lines below.
+func DrawCountdown(c Countdown) {
+	drawTimer(timerScreen{
+		total:    c.Total,
+		elapsed:  c.Elapsed,
+		title:    c.title(),
+		subtitle: c.Project,
+		minutes:  theme.Minutes,
+		seconds:  theme.Seconds,
+		bar:      theme.Bar,
+		info:     c.session(),
+		status:   c.Status,
+		keys:     c.keys(),
+	})
+}
+
+// drawTimer draws a timer screen in the layout fitting the terminal: digits
+// in the selected font, compact digits, then a single line.
+func drawTimer(s timerScreen) {
+
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 	w, h := termbox.Size()
 
-	drawProgressBar(currentseconds, totalSecond, w, h/2+6)
+	switch {
+	case w >= font.Width("00:00")+4 && h >= font.Height()+6:
+		drawStacked(s, font, w, h)
+	case w >= compactFont.Width("00:00")+4 && h >= compactFont.Height()+4:
+		drawStacked(s, compactFont, w, h)
+	default:
+		drawSingleLine(s, w, h)
+	}
+	termbox.Flush()
+}
 
-	x := (w - 48) / 2 // Adjusted for the new size of the big numbers and colon
-	y := h/2 - 3      // Centering vertically
+// drawStacked draws the time in digits of font f, with the other lines
+// around it as far as they fit, the least important left out first.
+func drawStacked(s timerScreen, f Font, w, h int) {
+	// title, subtitle, digits, progress bar and info
+	block := f.Height() + 4
+	spacing := 0
+	if h >= block+6 {
+		spacing = 1
+	}
+	block += 2 * spacing
 
-	for idx, r := range countdownString {
-		color := termbox.ColorGreen // Default color for minutes
-		if idx >= 3 {               // Change color for seconds
-			color = termbox.ColorRed
+	y := (h - block) / 2
+	if y < 0 {
+		y = 0
+	}
+	drawCentered(fit(s.title, w), w, y, theme.Title)
+	if s.subtitle != "" {
+		drawCentered(fit(s.subtitle, w), w, y+1, theme.Project)
+	}
+	y += 2 + spacing
+
+	f.draw(remaining(s.total, s.elapsed), w, y, s.minutes, s.seconds)
+	y += f.Height() + spacing
+
+	drawProgressBar(int(s.elapsed.Seconds()), int(s.total.Seconds()), w, y, s.bar)
+	drawCentered(fit(s.info, w), w, y+1, theme.Text)
+
+	// the last row is kept for the key hints
+	row := y + 2 + spacing
+	for _, line := range s.status {
+		if row >= h-1 {
+			break
 		}
-		for i, line := range bigNumbers[r] {
-			for j, ch := range line {
-				if ch != ' ' {
-					termbox.SetCell(x+j, y+i, ch, color, termbox.ColorDefault)
-				}
-			}
+		if line != "" {
+			drawCentered(fit(line, w), w, row, theme.Text)
+			row++
 		}
-		x += 8 // Adjust the position for the next character, increase if needed
 	}
-	termbox.Flush()
+	if row < h {
+		drawCentered(fit(s.keys, w), w, h-1, theme.Hint)
+	}
 }
 
-func ClearScreen() {
-	cmd := exec.Command("clear")
-	cmd.Stdout = os.Stdout
-	cmd.Run()
+// drawSingleLine draws the remaining time, a small progress bar and the title
+// on the middle row, for panes too small for digits.
+func drawSingleLine(s timerScreen, w, h int) {
+	y := h / 2
+	x := 0
+	put := func(text string, fg termbox.Attribute) {
+		for _, r := range text {
+			if x >= w {
+				return
+			}
+			termbox.SetCell(x, y, r, fg, termbox.ColorDefault)
+			x++
+		}
+	}
+
+	put(remaining(s.total, s.elapsed), s.minutes)
+
+	barWidth := (w - 6) / 3
+	if barWidth > 20 {
+		barWidth = 20
+	}
+	if barWidth >= 3 {
+		filled := 0
+		if s.total > 0 {
+			filled = int(float64(barWidth) * float64(s.elapsed) / float64(s.total))
+		}
+		if filled > barWidth {
+			filled = barWidth
+		}
+		put(" ", termbox.ColorDefault)
+		put(strings.Repeat("█", filled), s.bar)
+		put(strings.Repeat("░", barWidth-filled), theme.BarEmpty)
+	}
+
+	if rest := w - x - 1; rest > 0 {
+		put(" "+fit(s.title, rest), theme.Title)
+	}
 }
 
-func DrawProgressBar(current, total float64) {
-	progress := current / total
-	numBlocks := int(progress * float64(progressBarWidth))
-	fmt.Print("[")
-	for i := 0; i < numBlocks; i++ {
-		fmt.Print("█")
+// fit shortens text to width columns.
+func fit(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
 	}
-	for i := 0; i < progressBarWidth-numBlocks; i++ {
-		fmt.Print(" ")
+	if width <= 1 {
+		return ""
 	}
-	fmt.Print("]")
-	fmt.Printf(" %.2f%% %d/%d %.2f/%.f\n", progress*100, int(current), int(total), current/60.0, total/60)
+	return string(runes[:width-1]) + "…"
+}
 
+// drawCentered draws text horizontally centered on row y.
+func drawCentered(text string, width, y int, color termbox.Attribute) {
+	x := (width - len([]rune(text))) / 2
+	for i, r := range []rune(text) {
+		termbox.SetCell(x+i, y, r, color, termbox.ColorDefault)
+	}
 }
 
-var bigNumbers = map[rune][]string{
-	':': {
-		"     ",
-		"  *  ",
-		"     ",
-		"  *  ",
-		"     ",
-	},
-	'0': {
-		" 000 ",
-		"0   0",
-		"0   0",
-		"0   0",
-		" 000 ",
-	},
-	'1': {
-		" X ",
-		"XX ",
-		" X ",
-		" X ",
-		"XXX",
-	},
-	'2': {
-		"XXX",
-		"  X",
-		"XXX",
-		"X  ",
-		"XXX",
-	},
-	'3': {
-		"XXX",
-		"  X",
-		"XXX",
-		"  X",
-		"XXX",
-	},
-	'4': {
-		"X X",
-		"X X",
-		"XXX",
-		"  X",
-		"  X",
-	},
-	'5': {
-		"XXX",
-		"X  ",
-		"XXX",
-		"  X",
-		"XXX",
-	},
-	'6': {
-		"XXX",
-		"X  ",
-		"XXX",
-		"X X",
-		"XXX",
-	},
-	'7': {
-		"XXX",
-		"  X",
-		"  X",
-		" X ",
-		" X ",
-	},
-	'8': {
-		"XXX",
-		"X X",
-		"XXX",
-		"X X",
-		"XXX",
-	},
-	'9': {
-		"XXX",
-		"X X",
-		"XXX",
-		"  X",
-		"  X",
-	},
+func ClearScreen() {
+	cmd := exec.Command("clear")
+	cmd.Stdout = os.Stdout
+	cmd.Run()
 }
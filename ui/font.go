@@ -0,0 +1,199 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+	"github.com/spf13/viper"
+)
+
+// Font is a set of digit glyphs of equal height, drawn Gap columns apart.
+type Font struct {
+	Glyphs map[rune][]string
+	Gap    int
+}
+
+// fontRunes are the characters every font must draw.
+const fontRunes = "0123456789:"
+
+// Height returns the number of rows of the glyphs.
+func (f Font) Height() int {
+	return len(f.Glyphs['0'])
+}
+
+// Width returns the number of columns text takes in the font.
+func (f Font) Width(text string) int {
+	width := 0
+	for i, r := range text {
+		if i > 0 {
+			width += f.Gap
+		}
+		width += glyphWidth(f.Glyphs[r])
+	}
+	return width
+}
+
+func glyphWidth(glyph []string) int {
+	width := 0
+	for _, line := range glyph {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
+// draw draws "mm:ss" centered on a screen width columns wide, from row y on,
+// the minutes and the seconds in their own colors.
+func (f Font) draw(text string, width, y int, minutes, seconds termbox.Attribute) {
+	x := (width - f.Width(text)) / 2
+	for idx, r := range text {
+		color := minutes
+		if idx >= 3 {
+			color = seconds
+		}
+		glyph := f.Glyphs[r]
+		drawGlyph(glyph, x, y, color)
+		x += glyphWidth(glyph) + f.Gap
+	}
+}
+
+// drawGlyph draws the non-blank cells of glyph from x, y on.
+func drawGlyph(glyph []string, x, y int, color termbox.Attribute) {
+	for i, line := range glyph {
+		for j, ch := range []rune(line) {
+			if ch != ' ' {
+				termbox.SetCell(x+j, y+i, ch, color, termbox.ColorDefault)
+			}
+		}
+	}
+}
+
+// scaled returns the font with every cell of its glyphs replaced by fill,
+// and blanks widened to match.
+func scaled(f Font, fill string, gap int) Font {
+	blank := strings.Repeat(" ", len([]rune(fill)))
+	glyphs := make(map[rune][]string, len(f.Glyphs))
+	for r, glyph := range f.Glyphs {
+		lines := make([]string, len(glyph))
+		for i, line := range glyph {
+			var b strings.Builder
+			for _, ch := range line {
+				if ch == ' ' {
+					b.WriteString(blank)
+				} else {
+					b.WriteString(fill)
+				}
+			}
+			lines[i] = b.String()
+		}
+		glyphs[r] = lines
+	}
+	return Font{Glyphs: glyphs, Gap: gap}
+}
+
+// classicFont draws digits five rows high out of X.
+var classicFont = Font{Gap: 2, Glyphs: map[rune][]string{
+	':': {" ", "X", " ", "X", " "},
+	'0': {"XXX", "X X", "X X", "X X", "XXX"},
+	'1': {" X ", "XX ", " X ", " X ", "XXX"},
+	'2': {"XXX", "  X", "XXX", "X  ", "XXX"},
+	'3': {"XXX", "  X", "XXX", "  X", "XXX"},
+	'4': {"X X", "X X", "XXX", "  X", "  X"},
+	'5': {"XXX", "X  ", "XXX", "  X", "XXX"},
+	'6': {"XXX", "X  ", "XXX", "X X", "XXX"},
+	'7': {"XXX", "  X", "  X", " X ", " X "},
+	'8': {"XXX", "X X", "XXX", "X X", "XXX"},
+	'9': {"XXX", "X X", "XXX", "  X", "  X"},
+}}
+
+// compactFont draws digits three rows high as seven segments, for small
+// terminals.
+var compactFont = Font{Gap: 1, Glyphs: map[rune][]string{
+	':': {" ", ".", "."},
+	'0': {" _ ", "| |", "|_|"},
+	'1': {"   ", "  |", "  |"},
+	'2': {" _ ", " _|", "|_ "},
+	'3': {" _ ", " _|", " _|"},
+	'4': {"   ", "|_|", "  |"},
+	'5': {" _ ", "|_ ", " _|"},
+	'6': {" _ ", "|_ ", "|_|"},
+	'7': {" _ ", "  |", "  |"},
+	'8': {" _ ", "|_|", "|_|"},
+	'9': {" _ ", "|_|", " _|"},
+}}
+
+// fonts are the built-in fonts by name.
+var fonts = map[string]Font{
+	"classic": classicFont,
+	"block":   scaled(classicFont, "██", 2),
+	"shade":   scaled(classicFont, "▓", 2),
+	"compact": compactFont,
+}
+
+// font is the font of the countdown digits.
+var font = classicFont
+
+// SetFont selects a built-in font by name, or loads one from a file when name
+// is not a built-in font. An empty name keeps the default font.
+func SetFont(name string) error {
+	if name == "" {
+		return nil
+	}
+	if f, ok := fonts[name]; ok {
+		font = f
+		return nil
+	}
+	f, err := LoadFont(name)
+	if err != nil {
+		return err
+	}
+	font = f
+	return nil
+}
+
+// LoadFont reads a font from a YAML file with the glyph rows of every digit
+// and the colon:
+//
+//	gap: 2
+//	glyphs:
+//	  "0": ["###", "# #", "# #", "# #", "###"]
+//	  ...
+func LoadFont(path string) (Font, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return Font{}, err
+	}
+
+	var file struct {
+		Gap    int
+		Glyphs map[string][]string
+	}
+	if err := v.Unmarshal(&file); err != nil {
+		return Font{}, err
+	}
+
+	f := Font{Glyphs: make(map[rune][]string), Gap: file.Gap}
+	for key, glyph := range file.Glyphs {
+		runes := []rune(key)
+		if len(runes) != 1 {
+			return Font{}, fmt.Errorf("glyph %q is not a single character", key)
+		}
+		f.Glyphs[runes[0]] = glyph
+	}
+	for _, r := range fontRunes {
+		glyph, ok := f.Glyphs[r]
+		if !ok {
+			return Font{}, fmt.Errorf("font has no glyph for %q", r)
+		}
+		if len(glyph) != f.Height() {
+			return Font{}, fmt.Errorf("glyph %q is %d rows high instead of %d", r, len(glyph), f.Height())
+		}
+	}
+	if f.Height() == 0 {
+		return Font{}, fmt.Errorf("font glyphs are empty")
+	}
+	return f, nil
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Theme holds the colors of the screen elements.
+type Theme struct {
+	Minutes  termbox.Attribute
+	Seconds  termbox.Attribute
+	Title    termbox.Attribute
+	Project  termbox.Attribute
+	Text     termbox.Attribute
+	Hint     termbox.Attribute
+	Bar      termbox.Attribute
+	BarEmpty termbox.Attribute
+	// Break is the color of the break timer.
+	Break termbox.Attribute
+}
+
+// themes are the built-in color themes by name.
+var themes = map[string]Theme{
+	"default": {
+		Minutes:  termbox.ColorGreen,
+		Seconds:  termbox.ColorRed,
+		Title:    termbox.ColorWhite | termbox.AttrBold,
+		Project:  termbox.ColorCyan,
+		Text:     termbox.ColorWhite,
+		Hint:     termbox.ColorDefault,
+		Bar:      termbox.ColorGreen,
+		BarEmpty: termbox.ColorLightGray,
+		Break:    termbox.ColorBlue,
+	},
+	"monochrome": {
+		Minutes:  termbox.ColorDefault | termbox.AttrBold,
+		Seconds:  termbox.ColorDefault,
+		Title:    termbox.ColorDefault | termbox.AttrBold,
+		Project:  termbox.ColorDefault,
+		Text:     termbox.ColorDefault,
+		Hint:     termbox.ColorDefault,
+		Bar:      termbox.ColorDefault,
+		BarEmpty: termbox.ColorDefault,
+		Break:    termbox.ColorDefault,
+	},
+	"high-contrast": {
+		Minutes:  termbox.ColorWhite | termbox.AttrBold,
+		Seconds:  termbox.ColorYellow | termbox.AttrBold,
+		Title:    termbox.ColorWhite | termbox.AttrBold,
+		Project:  termbox.ColorYellow | termbox.AttrBold,
+		Text:     termbox.ColorWhite | termbox.AttrBold,
+		Hint:     termbox.ColorWhite,
+		Bar:      termbox.ColorYellow,
+		BarEmpty: termbox.ColorWhite,
+		Break:    termbox.ColorCyan | termbox.AttrBold,
+	},
+}
+
+// theme is the color theme of the screen.
+var theme = themes["default"]
+
+// SetTheme selects a built-in theme by name, the default one when name is
+// empty. NO_COLOR forces the monochrome theme.
+func SetTheme(name string) error {
+	if os.Getenv("NO_COLOR") != "" {
+		name = "monochrome"
+	}
+	if name == "" {
+		name = "default"
+	}
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+	theme = t
+	return nil
+}